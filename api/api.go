@@ -18,6 +18,11 @@ type ApiResponse struct {
 
 // callPOST is a helper for POST requests. If you need to pass a token, supply it via the `token` parameter.
 func callPOST(url, token string, body interface{}) (ApiResponse, error) {
+	return callPOSTWithHeaders(url, token, body, nil)
+}
+
+// callPOSTWithHeaders is callPOST with extra request headers, e.g. a per-call microversion.
+func callPOSTWithHeaders(url, token string, body interface{}, headers map[string]string) (ApiResponse, error) {
 	apiResp := ApiResponse{}
 
 	// Marshal the request struct (whatever type it is) into JSON.
@@ -26,7 +31,7 @@ func callPOST(url, token string, body interface{}) (ApiResponse, error) {
 		return apiResp, fmt.Errorf("error marshaling JSON payload: %v", err)
 	}
 
-	resp, err := httpclient.SendRequestWithToken("POST", url, token, bytes.NewBuffer(jsonData))
+	resp, err := httpclient.SendRequestWithHeaders("POST", url, token, bytes.NewBuffer(jsonData), headers)
 	if err != nil {
 		return apiResp, fmt.Errorf("error making HTTP POST request: %v", err)
 	}
@@ -48,6 +53,38 @@ func callPOST(url, token string, body interface{}) (ApiResponse, error) {
 	return apiResp, nil
 }
 
+// callJSON is a helper for PUT/PATCH requests with a JSON body. contentType
+// overrides the default application/json, e.g. for JSON-patch payloads.
+func callJSON(method, url, token, contentType string, body interface{}) (ApiResponse, error) {
+	apiResp := ApiResponse{}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return apiResp, fmt.Errorf("error marshaling JSON payload: %v", err)
+	}
+
+	var headers map[string]string
+	if contentType != "" {
+		headers = map[string]string{"Content-Type": contentType}
+	}
+
+	resp, err := httpclient.SendRequestWithHeaders(method, url, token, bytes.NewBuffer(jsonData), headers)
+	if err != nil {
+		return apiResp, fmt.Errorf("error making HTTP %s request: %v", method, err)
+	}
+	defer resp.Body.Close()
+
+	apiResp.ResponseCode = resp.StatusCode
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return apiResp, fmt.Errorf("error reading response body: %v", err)
+	}
+	apiResp.Response = string(bodyBytes)
+
+	return apiResp, nil
+}
+
 // callGET is a helper for GET requests that requires a token in the X-Auth-Token header.
 func callGET(url, token string) (ApiResponse, error) {
 	apiResp := ApiResponse{}
@@ -91,10 +128,10 @@ func callDELETE(url, token string) (ApiResponse, error) {
 }
 
 // callBigPUT is a helper for large binary PUT requests
-func callBigPUT(url, token string, data io.Reader) (ApiResponse, error) {
+func callBigPUT(url, token string, data io.Reader, opts httpclient.UploadOptions) (ApiResponse, error) {
 	apiResp := ApiResponse{}
 
-	resp, err := httpclient.UploadBigFile(url, token, data)
+	resp, err := httpclient.UploadBigFile(url, token, data, opts)
 	if err != nil {
 		return apiResp, fmt.Errorf("error making HTTP PUT request: %v", err)
 	}
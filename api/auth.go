@@ -12,6 +12,10 @@ import (
 
 var TokenFile string
 
+// RenewWithin is how long before expiry a saved token stops being reused
+// by Authenticate and is replaced by a fresh one
+var RenewWithin time.Duration
+
 // TokenStore structure to store tokens per host
 type TokenStore struct {
 	Tokens map[string]Token `json:"tokens"` // map[hostname]Token
@@ -24,6 +28,14 @@ type Token struct {
 	Host      string            `json:"host"`
 	Endpoints map[string]string `json:"endpoints,omitempty"`
 	Project   string            `json:"project,omitempty"`
+
+	// who the token is for; empty in tokens saved by older versions
+	ProjectID         string     `json:"project_id,omitempty"`
+	UserID            string     `json:"user_id,omitempty"`
+	UserName          string     `json:"user_name,omitempty"`
+	UserDomain        string     `json:"user_domain,omitempty"`
+	Roles             []string   `json:"roles,omitempty"`
+	PasswordExpiresAt *time.Time `json:"password_expires_at,omitempty"`
 }
 
 // AuthPayload is used for the authentication request body
@@ -133,20 +145,32 @@ type AuthResponse struct {
 }
 
 // SaveToken saves or updates a token in the token store
-func SaveToken(host, project string, token string, expiresAt time.Time, endpoints map[string]string) error {
+func SaveToken(t Token) error {
 	store, err := loadTokenStore()
 	if err != nil {
 		store = TokenStore{Tokens: make(map[string]Token)}
 	}
 
-	store.Tokens[host] = Token{
-		Value:     token,
-		ExpiresAt: expiresAt,
-		Host:      host,
-		Endpoints: endpoints,
-		Project:   project,
+	store.Tokens[t.Host] = t
+	return writeTokenStore(store)
+}
+
+// ForgetToken removes the token of a host from the token store, so the
+// next authentication gets a new one
+func ForgetToken(host string) error {
+	store, err := loadTokenStore()
+	if err != nil {
+		return nil
+	}
+	if _, exists := store.Tokens[host]; !exists {
+		return nil
 	}
 
+	delete(store.Tokens, host)
+	return writeTokenStore(store)
+}
+
+func writeTokenStore(store TokenStore) error {
 	data, err := json.MarshalIndent(store, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal token store: %v", err)
@@ -158,18 +182,56 @@ func SaveToken(host, project string, token string, expiresAt time.Time, endpoint
 	return os.WriteFile(TokenFile, data, 0600)
 }
 
+// newToken builds the token to save from an auth response; only public
+// endpoints are kept
+func newToken(host, project, value string, authResponse AuthResponse) Token {
+	t := authResponse.Token
+	saved := Token{
+		Value:      value,
+		ExpiresAt:  t.ExpiresAt,
+		Host:       host,
+		Endpoints:  make(map[string]string),
+		Project:    project,
+		ProjectID:  t.Project.ID,
+		UserID:     t.User.ID,
+		UserName:   t.User.Name,
+		UserDomain: t.User.Domain.Name,
+	}
+	for _, svc := range t.Catalog {
+		for _, ep := range svc.Endpoints {
+			if ep.Interface == "public" {
+				saved.Endpoints[svc.Type] = ep.URL
+			}
+		}
+	}
+	for _, role := range t.Roles {
+		saved.Roles = append(saved.Roles, role.Name)
+	}
+	if expires, ok := t.User.PasswordExpiresAt.(string); ok {
+		if at, err := parseKeystoneTime(expires); err == nil {
+			saved.PasswordExpiresAt = &at
+		}
+	}
+	return saved
+}
+
+// parseKeystoneTime parses Keystone timestamps, which may lack a zone
+// and are then UTC
+func parseKeystoneTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05.999999", s, time.UTC)
+}
+
 // LoadToken loads the token for a specific host
 func LoadTokenStruct(host string) (Token, error) {
 	var t Token
 
-	store, err := loadTokenStore()
+	tokenObj, err := LoadSavedToken(host)
 	if err != nil {
 		return t, err
 	}
-	tokenObj, exists := store.Tokens[host]
-	if !exists {
-		return t, fmt.Errorf("no token found for host %s", host)
-	}
 
 	// Check expiration
 	if time.Now().After(tokenObj.ExpiresAt) {
@@ -179,6 +241,19 @@ func LoadTokenStruct(host string) (Token, error) {
 	return tokenObj, nil
 }
 
+// LoadSavedToken loads the token for a host even if it's expired
+func LoadSavedToken(host string) (Token, error) {
+	store, err := loadTokenStore()
+	if err != nil {
+		return Token{}, err
+	}
+	tokenObj, exists := store.Tokens[host]
+	if !exists {
+		return Token{}, fmt.Errorf("no token found for host %s", host)
+	}
+	return tokenObj, nil
+}
+
 func loadTokenStore() (TokenStore, error) {
 	var store TokenStore
 	data, err := os.ReadFile(TokenFile)
@@ -194,11 +269,24 @@ func loadTokenStore() (TokenStore, error) {
 	return store, nil
 }
 
+// reusableToken returns the saved token for host if it's for project and
+// outlives RenewWithin. Tokens saved without the user's details are
+// renewed too. The saved token is only replaced once a new one is issued.
+func reusableToken(host, project string) (Token, bool) {
+	existingToken, err := LoadTokenStruct(host)
+	if err != nil || existingToken.Project != project || existingToken.UserID == "" {
+		return Token{}, false
+	}
+	if time.Until(existingToken.ExpiresAt) < RenewWithin {
+		return Token{}, false
+	}
+	return existingToken, true
+}
+
 // Authenticate uses domain/project names, calls the auth token API, and returns the token on success.
 func Authenticate(host, domain, project, username, password string) (string, error) {
 	// Attempt to load an existing token if it's valid
-	existingToken, err := LoadTokenStruct(host)
-	if err == nil && project == existingToken.Project {
+	if existingToken, ok := reusableToken(host, project); ok {
 		fmt.Printf("Using existing token for %s, project %s\n", host, project)
 		return existingToken.Value, nil
 	}
@@ -224,20 +312,8 @@ func Authenticate(host, domain, project, username, password string) (string, err
 	if err != nil {
 		return "", fmt.Errorf("failed to parse auth response: %v", err)
 	}
-	expiresAt := authResponse.Token.ExpiresAt
-
-	// Extract "public" endpoints we care about from the catalog
-	endpoints := make(map[string]string)
-	for _, svc := range authResponse.Token.Catalog {
-		for _, ep := range svc.Endpoints {
-			if ep.Interface == "public" {
-				endpoints[svc.Type] = ep.URL
-			}
-		}
-	}
-
 	// Save token + endpoints
-	err = SaveToken(host, project, apiResp.TokenHeader, expiresAt, endpoints)
+	err = SaveToken(newToken(host, project, apiResp.TokenHeader, authResponse))
 	if err != nil {
 		return "", fmt.Errorf("failed to save token: %v", err)
 	}
@@ -250,9 +326,8 @@ func Authenticate(host, domain, project, username, password string) (string, err
 // TODO: Fix this
 func AuthenticateById(host, domainID, project, username, password string) (string, error) {
 	// Try existing token first
-	existingToken, err := LoadTokenStruct(host)
-	if err == nil {
-		return existingToken.Project, nil
+	if existingToken, ok := reusableToken(host, project); ok {
+		return existingToken.Value, nil
 	}
 
 	url := fmt.Sprintf("https://%s:5000/v3/auth/tokens", host)
@@ -294,20 +369,8 @@ func AuthenticateById(host, domainID, project, username, password string) (strin
 	if err != nil {
 		return "", fmt.Errorf("failed to parse auth response: %v", err)
 	}
-	expiresAt := authResponse.Token.ExpiresAt
-
-	// Extract "public" endpoints
-	endpoints := make(map[string]string)
-	for _, svc := range authResponse.Token.Catalog {
-		for _, ep := range svc.Endpoints {
-			if ep.Interface == "public" {
-				endpoints[svc.Type] = ep.URL
-			}
-		}
-	}
-
-	// Save
-	err = SaveToken(host, project, apiResp.TokenHeader, expiresAt, endpoints)
+	// Save token + endpoints
+	err = SaveToken(newToken(host, project, apiResp.TokenHeader, authResponse))
 	if err != nil {
 		return "", fmt.Errorf("failed to save token: %v", err)
 	}
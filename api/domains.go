@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 )
 
 // DomainListResponse is the JSON structure returned by GET /v3/domains
@@ -35,3 +36,52 @@ func ListDomains(identityUrl, token string) (DomainListResponse, error) {
 
 	return result, nil
 }
+
+// GetDomainIDByName returns the ID of the domain named domainName.
+func GetDomainIDByName(identityUrl, token, domainName string) (string, error) {
+	var result DomainListResponse
+
+	apiResp, err := callGET(fmt.Sprintf("%s/domains?name=%s", identityUrl, url.QueryEscape(domainName)), token)
+	if err != nil {
+		return "", fmt.Errorf("failed to get domain ID: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return "", fmt.Errorf("get domain ID failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return "", fmt.Errorf("error unmarshalling domain list: %v", err)
+	}
+	if len(result.Domains) == 0 {
+		return "", fmt.Errorf("no domain found for name %s", domainName)
+	}
+	return result.Domains[0].ID, nil
+}
+
+// DomainDetail is a Keystone domain.
+type DomainDetail struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// GetDomain calls GET /v3/domains/{id}.
+func GetDomain(identityUrl, token, domainID string) (DomainDetail, error) {
+	var result struct {
+		Domain DomainDetail `json:"domain"`
+	}
+
+	apiResp, err := callGET(fmt.Sprintf("%s/domains/%s", identityUrl, url.PathEscape(domainID)), token)
+	if err != nil {
+		return result.Domain, fmt.Errorf("failed to get domain: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result.Domain, fmt.Errorf("get domain failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return result.Domain, fmt.Errorf("error unmarshalling domain: %v", err)
+	}
+	return result.Domain, nil
+}
@@ -17,21 +17,24 @@ type Flavor struct {
 	} `json:"links"`
 }
 
+// FlavorDetail is a flavor with its full set of attributes.
+type FlavorDetail struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	RAM         int               `json:"ram"`
+	Disk        int               `json:"disk"`
+	VCPUs       int               `json:"vcpus"`
+	Swap        interface{}       `json:"swap"` // sometimes a string (""), sometimes an int
+	RxTxFactor  float64           `json:"rxtx_factor"`
+	IsPublic    bool              `json:"os-flavor-access:is_public"`
+	Ephemeral   int               `json:"OS-FLV-EXT-DATA:ephemeral"`
+	IsDisabled  bool              `json:"OS-FLV-DISABLED:disabled"`
+	Description string            `json:"description,omitempty"` // microversion>=2.55
+	ExtraSpecs  map[string]string `json:"extra_specs,omitempty"` // microversion>=2.61
+}
+
 type FlavorDetailResp struct {
-	Flavor struct {
-		ID          string            `json:"id"`
-		Name        string            `json:"name"`
-		RAM         int               `json:"ram"`
-		Disk        int               `json:"disk"`
-		VCPUs       int               `json:"vcpus"`
-		Swap        string            `json:"swap"` // sometimes a string (""), sometimes an int
-		RxTxFactor  float64           `json:"rxtx_factor"`
-		IsPublic    bool              `json:"os-flavor-access:is_public"`
-		Ephemeral   int               `json:"OS-FLV-EXT-DATA:ephemeral"`
-		IsDisabled  bool              `json:"OS-FLV-DISABLED:disabled"`
-		Description string            `json:"description,omitempty"` // microversion>=2.55
-		ExtraSpecs  map[string]string `json:"extra_specs,omitempty"` // microversion>=2.61
-	} `json:"flavor"`
+	Flavor FlavorDetail `json:"flavor"`
 }
 
 type FlavorListResponse struct {
@@ -66,6 +69,27 @@ func ListFlavors(computeURL, token string, queryParams map[string]string) (Flavo
 	return result, nil
 }
 
+// ListFlavorsDetail fetches all flavors with their vCPUs, RAM and disk
+func ListFlavorsDetail(computeURL, token string) ([]FlavorDetail, error) {
+	var result struct {
+		Flavors []FlavorDetail `json:"flavors"`
+	}
+
+	url := fmt.Sprintf("%s/flavors/detail", computeURL)
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch flavors: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("flavors request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse flavors response: %v", err)
+	}
+	return result.Flavors, nil
+}
+
 func GetFlavorDetails(computeURL, token, flavorID string) (FlavorDetailResp, error) {
 	var result FlavorDetailResp
 
@@ -112,3 +136,155 @@ func GetFlavorIDByName(computeURL, token, flavorName string) (string, error) {
 
 	return foundFlavors[0].ID, nil
 }
+
+// CreateFlavorRequest is the body of a flavor create; ID is generated by
+// Nova when empty
+type CreateFlavorRequest struct {
+	Flavor struct {
+		Name        string `json:"name"`
+		ID          string `json:"id,omitempty"`
+		RAM         int    `json:"ram"`
+		VCPUs       int    `json:"vcpus"`
+		Disk        int    `json:"disk"`
+		Ephemeral   int    `json:"OS-FLV-EXT-DATA:ephemeral,omitempty"`
+		Swap        int    `json:"swap,omitempty"`
+		IsPublic    bool   `json:"os-flavor-access:is_public"`
+		Description string `json:"description,omitempty"` // microversion>=2.55
+	} `json:"flavor"`
+}
+
+// CreateFlavor creates a flavor and returns it.
+func CreateFlavor(computeURL, token string, request CreateFlavorRequest) (FlavorDetail, error) {
+	var result FlavorDetailResp
+
+	url := fmt.Sprintf("%s/flavors", computeURL)
+
+	apiResp, err := callPOST(url, token, request)
+	if err != nil {
+		return result.Flavor, fmt.Errorf("failed to create flavor: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result.Flavor, fmt.Errorf("create flavor request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return result.Flavor, fmt.Errorf("failed to parse flavor response: %v", err)
+	}
+	return result.Flavor, nil
+}
+
+// DeleteFlavor deletes a flavor. VMs already using it keep running.
+func DeleteFlavor(computeURL, token, flavorID string) error {
+	url := fmt.Sprintf("%s/flavors/%s", computeURL, flavorID)
+
+	apiResp, err := callDELETE(url, token)
+	if err != nil {
+		return fmt.Errorf("failed to delete flavor: %v", err)
+	}
+	if apiResp.ResponseCode != 202 {
+		return fmt.Errorf("delete flavor request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
+
+// GetFlavorExtraSpecs returns the extra specs of a flavor.
+func GetFlavorExtraSpecs(computeURL, token, flavorID string) (map[string]string, error) {
+	var result struct {
+		ExtraSpecs map[string]string `json:"extra_specs"`
+	}
+
+	url := fmt.Sprintf("%s/flavors/%s/os-extra_specs", computeURL, flavorID)
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch flavor extra specs: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("flavor extra specs request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse flavor extra specs: %v", err)
+	}
+	return result.ExtraSpecs, nil
+}
+
+// SetFlavorExtraSpecs adds or replaces extra specs of a flavor, e.g.
+// hw:cpu_policy=dedicated or quota:disk_read_iops_sec=1000.
+func SetFlavorExtraSpecs(computeURL, token, flavorID string, specs map[string]string) error {
+	url := fmt.Sprintf("%s/flavors/%s/os-extra_specs", computeURL, flavorID)
+
+	request := map[string]map[string]string{"extra_specs": specs}
+	apiResp, err := callPOST(url, token, request)
+	if err != nil {
+		return fmt.Errorf("failed to set flavor extra specs: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return fmt.Errorf("set flavor extra specs request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
+
+// DeleteFlavorExtraSpec removes one extra spec from a flavor.
+func DeleteFlavorExtraSpec(computeURL, token, flavorID, key string) error {
+	url := fmt.Sprintf("%s/flavors/%s/os-extra_specs/%s", computeURL, flavorID, key)
+
+	apiResp, err := callDELETE(url, token)
+	if err != nil {
+		return fmt.Errorf("failed to delete flavor extra spec: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return fmt.Errorf("delete flavor extra spec request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
+
+// FlavorAccess is a project allowed to use a private flavor.
+type FlavorAccess struct {
+	FlavorID  string `json:"flavor_id"`
+	ProjectID string `json:"tenant_id"`
+}
+
+// ListFlavorAccess returns the projects that can use a private flavor.
+func ListFlavorAccess(computeURL, token, flavorID string) ([]FlavorAccess, error) {
+	var result struct {
+		FlavorAccess []FlavorAccess `json:"flavor_access"`
+	}
+
+	url := fmt.Sprintf("%s/flavors/%s/os-flavor-access", computeURL, flavorID)
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch flavor access: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("flavor access request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse flavor access: %v", err)
+	}
+	return result.FlavorAccess, nil
+}
+
+// AddFlavorAccess lets a project use a private flavor.
+func AddFlavorAccess(computeURL, token, flavorID, projectID string) error {
+	return flavorAccessAction(computeURL, token, flavorID, "addTenantAccess", projectID)
+}
+
+// RemoveFlavorAccess revokes a project's access to a private flavor.
+func RemoveFlavorAccess(computeURL, token, flavorID, projectID string) error {
+	return flavorAccessAction(computeURL, token, flavorID, "removeTenantAccess", projectID)
+}
+
+func flavorAccessAction(computeURL, token, flavorID, action, projectID string) error {
+	url := fmt.Sprintf("%s/flavors/%s/action", computeURL, flavorID)
+
+	request := map[string]map[string]string{action: {"tenant": projectID}}
+	apiResp, err := callPOST(url, token, request)
+	if err != nil {
+		return fmt.Errorf("failed to send %s request: %v", action, err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return fmt.Errorf("%s request failed [%d]: %s", action, apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
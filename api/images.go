@@ -1,11 +1,17 @@
 package api
 
 import (
+	"crypto/md5"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jessegalley/vhicmd/internal/httpclient"
 	"github.com/spf13/viper"
@@ -20,6 +26,18 @@ type Image struct {
 	MinDisk int    `json:"min_disk"`
 	MinRAM  int    `json:"min_ram"`
 	Owner   string `json:"owner"`
+	DiskBus string `json:"hw_disk_bus,omitempty"`
+
+	// Stable rescue of volume-backed VMs needs these on the rescue image
+	RescueDevice string `json:"hw_rescue_device,omitempty"`
+	RescueBus    string `json:"hw_rescue_bus,omitempty"`
+
+	Visibility      string `json:"visibility,omitempty"`
+	DiskFormat      string `json:"disk_format,omitempty"`
+	ContainerFormat string `json:"container_format,omitempty"`
+	Checksum        string `json:"checksum,omitempty"` // md5, legacy
+	OSHashAlgo      string `json:"os_hash_algo,omitempty"`
+	OSHashValue     string `json:"os_hash_value,omitempty"`
 }
 
 type CreateImageRequest struct {
@@ -31,8 +49,59 @@ type CreateImageRequest struct {
 	Protected    bool     `json:"protected,omitempty"`
 	Tags         []string `json:"tags,omitempty"`
 	Visibility   string   `json:"visibility,omitempty"`
+
+	// Properties are custom image properties (hw_disk_bus, os_type, ...),
+	// sent as top-level keys alongside the fields above.
+	Properties map[string]string `json:"-"`
 }
 
+// MarshalJSON merges the custom properties into the request body, with
+// min_disk, min_ram and protected converted from strings as Glance wants.
+func (r CreateImageRequest) MarshalJSON() ([]byte, error) {
+	type plain CreateImageRequest
+	data, err := json.Marshal(plain(r))
+	if err != nil || len(r.Properties) == 0 {
+		return data, err
+	}
+
+	merged := make(map[string]interface{})
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range r.Properties {
+		v, err := imagePropertyValue(key, value)
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = v
+	}
+	return json.Marshal(merged)
+}
+
+// ImageMember represents a project an image is shared with.
+type ImageMember struct {
+	ImageID   string `json:"image_id"`
+	MemberID  string `json:"member_id"`
+	Status    string `json:"status"` // pending, accepted, rejected
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// ImageMemberListResponse represents the response for listing image members.
+type ImageMemberListResponse struct {
+	Members []ImageMember `json:"members"`
+}
+
+// imagePatchOp is a single JSON-patch operation on an image.
+type imagePatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// imagePatchContentType is the media type Glance requires for image updates
+const imagePatchContentType = "application/openstack-images-v2.1-json-patch"
+
 // ImageListResponse is the structure for the API response.
 type ImageListResponse struct {
 	Images []Image `json:"images"`
@@ -110,15 +179,24 @@ func createImage(computeURL, token string, req CreateImageRequest) (string, erro
 	return result.ID, nil
 }
 
+// UploadOptions controls how CreateAndUploadImageWithOptions sends image data.
+type UploadOptions struct {
+	Size     int64     // length of data that isn't a regular *os.File, 0 if unknown
+	BwLimit  int64     // bytes per second, 0 for unlimited
+	Retries  int       // extra attempts after a failed upload, each from byte 0; needs a seekable reader
+	UseStage bool      // upload via /stage + glance-direct import instead of /file
+	Output   io.Writer // progress and retry messages, nil for stdout
+}
+
 // UploadImageData uploads the actual image data
-func uploadImageData(computeURL, token, imageID string, data io.Reader) error {
-	url := fmt.Sprintf("%s/v2/images/%s/file", computeURL, imageID)
+func uploadImageData(computeURL, token, imageID, endpoint string, data io.Reader, opts httpclient.UploadOptions) error {
+	url := fmt.Sprintf("%s/v2/images/%s/%s", computeURL, imageID, endpoint)
 
 	if viper.GetBool("debug") {
 		fmt.Printf("Attempting upload to URL: %s\n", url)
 	}
 
-	resp, err := httpclient.UploadBigFile(url, token, data)
+	resp, err := httpclient.UploadBigFile(url, token, data, opts)
 	if err != nil {
 		return fmt.Errorf("upload failed: %v", err)
 	}
@@ -132,8 +210,54 @@ func uploadImageData(computeURL, token, imageID string, data io.Reader) error {
 	return nil
 }
 
+// importImage starts an interoperable image import. method is the import
+// method body, e.g. {"name": "glance-direct"} for previously staged data.
+func importImage(computeURL, token, imageID string, method map[string]string) error {
+	url := fmt.Sprintf("%s/v2/images/%s/import", computeURL, imageID)
+
+	request := map[string]interface{}{
+		"method": method,
+	}
+
+	apiResp, err := callPOST(url, token, request)
+	if err != nil {
+		return fmt.Errorf("import request failed: %v", err)
+	}
+	if apiResp.ResponseCode != 202 {
+		return fmt.Errorf("import request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
+
+// WaitForImageStatus polls image status until it matches target or times out.
+// Imports of large images can take a while, so this waits up to an hour.
+func WaitForImageStatus(computeURL, token, imageID, targetStatus string) (Image, error) {
+	maxAttempts := 360 // ~1 hour with 10s intervals
+	for i := 0; i < maxAttempts; i++ {
+		img, err := GetImageDetails(computeURL, token, imageID)
+		if err != nil {
+			return img, fmt.Errorf("failed to get image status: %v", err)
+		}
+		if img.Status == targetStatus {
+			return img, nil
+		}
+		if img.Status == "killed" || img.Status == "deleted" {
+			return img, fmt.Errorf("image entered %s state while waiting for %s", img.Status, targetStatus)
+		}
+		time.Sleep(10 * time.Second)
+	}
+	return Image{}, fmt.Errorf("timeout waiting for image to become %s", targetStatus)
+}
+
 // CreateAndUploadImage creates an image and uploads the image data
 func CreateAndUploadImage(computeURL, token string, req CreateImageRequest, data io.Reader) (string, error) {
+	return CreateAndUploadImageWithOptions(computeURL, token, req, data, UploadOptions{})
+}
+
+// CreateAndUploadImageWithOptions creates an image and uploads the image data,
+// hashing it on the way so the result can be checked against Glance's checksum.
+// Failed uploads are retried from the start when data is an io.Seeker.
+func CreateAndUploadImageWithOptions(computeURL, token string, req CreateImageRequest, data io.Reader, opts UploadOptions) (string, error) {
 	// Create image with disk_format and container_format specified
 	if req.DiskFmt == "" {
 		return "", fmt.Errorf("disk_format must be specified")
@@ -142,22 +266,148 @@ func CreateAndUploadImage(computeURL, token string, req CreateImageRequest, data
 		return "", fmt.Errorf("container_format must be specified")
 	}
 
+	// The size must be known before data is wrapped for hashing;
+	// pipes such as stdin stay at 0 and are uploaded chunked
+	size := opts.Size
+	seeker, canRetry := data.(io.Seeker)
+	if f, ok := data.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return "", fmt.Errorf("failed to get file size: %v", err)
+		}
+		if info.Mode().IsRegular() {
+			size = info.Size()
+			if size == 0 {
+				return "", fmt.Errorf("refusing to upload empty file (size=0)")
+			}
+		} else {
+			canRetry = false
+		}
+	}
+	if opts.Retries > 0 && !canRetry {
+		return "", fmt.Errorf("retries require a seekable input; use --retries 0 for streams")
+	}
+
 	imageID, err := createImage(computeURL, token, req)
 	if err != nil {
 		return imageID, fmt.Errorf("failed to create image: %v", err)
 	}
 
-	// Upload to the /file endpoint
-	err = uploadImageData(computeURL, token, imageID, data)
+	endpoint := "file"
+	if opts.UseStage {
+		endpoint = "stage"
+	}
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	httpOpts := httpclient.UploadOptions{Size: size, BwLimit: opts.BwLimit, Output: out}
+
+	sha := sha512.New()
+	sum := md5.New()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				_ = DeleteImage(computeURL, token, imageID)
+				return imageID, fmt.Errorf("failed to rewind input for retry: %v", err)
+			}
+		}
+		sha.Reset()
+		sum.Reset()
+
+		tee := io.TeeReader(data, io.MultiWriter(sha, sum))
+		err = uploadImageData(computeURL, token, imageID, endpoint, tee, httpOpts)
+		if err == nil {
+			break
+		}
+		if attempt >= opts.Retries {
+			// Try to clean up failed image
+			_ = DeleteImage(computeURL, token, imageID)
+			return imageID, fmt.Errorf("failed to upload image data: %v", err)
+		}
+
+		backoff := time.Duration(attempt+1) * 30 * time.Second
+		fmt.Fprintf(out, "\nUpload failed: %v\nRetrying in %s (attempt %d of %d)...\n", err, backoff, attempt+2, opts.Retries+1)
+		time.Sleep(backoff)
+	}
+
+	if opts.UseStage {
+		fmt.Fprintf(out, "\nData staged, importing image %s...\n", imageID)
+		if err := importImage(computeURL, token, imageID, map[string]string{"name": "glance-direct"}); err != nil {
+			_ = DeleteImage(computeURL, token, imageID)
+			return imageID, err
+		}
+	}
+
+	img, err := WaitForImageStatus(computeURL, token, imageID, "active")
 	if err != nil {
-		// Try to clean up failed image
 		_ = DeleteImage(computeURL, token, imageID)
-		return imageID, fmt.Errorf("failed to upload image data: %v", err)
+		return imageID, err
+	}
+
+	if err := verifyImageChecksum(out, img, req.DiskFmt, hex.EncodeToString(sha.Sum(nil)), hex.EncodeToString(sum.Sum(nil))); err != nil {
+		_ = DeleteImage(computeURL, token, imageID)
+		return imageID, err
 	}
 
 	return imageID, nil
 }
 
+// CreateImageFromURL creates an image and has Glance fetch the data itself
+// from uri using the web-download import method, then waits for it to be active.
+func CreateImageFromURL(computeURL, token string, req CreateImageRequest, uri string) (string, error) {
+	if req.DiskFmt == "" {
+		return "", fmt.Errorf("disk_format must be specified")
+	}
+	if req.ContainerFmt == "" {
+		return "", fmt.Errorf("container_format must be specified")
+	}
+
+	imageID, err := createImage(computeURL, token, req)
+	if err != nil {
+		return imageID, fmt.Errorf("failed to create image: %v", err)
+	}
+
+	err = importImage(computeURL, token, imageID, map[string]string{"name": "web-download", "uri": uri})
+	if err != nil {
+		_ = DeleteImage(computeURL, token, imageID)
+		return imageID, err
+	}
+
+	if _, err := WaitForImageStatus(computeURL, token, imageID, "active"); err != nil {
+		_ = DeleteImage(computeURL, token, imageID)
+		return imageID, err
+	}
+
+	return imageID, nil
+}
+
+// verifyImageChecksum compares the locally computed hashes with the ones
+// Glance recorded. Images converted during import are skipped, since their
+// stored data no longer matches what was sent.
+func verifyImageChecksum(out io.Writer, img Image, diskFmt, sha512Hex, md5Hex string) error {
+	if img.DiskFormat != "" && img.DiskFormat != diskFmt {
+		fmt.Fprintf(out, "Image converted to %s on import, skipping checksum verification\n", img.DiskFormat)
+		return nil
+	}
+
+	switch {
+	case img.OSHashAlgo == "sha512" && img.OSHashValue != "":
+		if img.OSHashValue != sha512Hex {
+			return fmt.Errorf("sha512 mismatch: uploaded %s, Glance has %s", sha512Hex, img.OSHashValue)
+		}
+		fmt.Fprintf(out, "Checksum verified (sha512)\n")
+	case img.Checksum != "":
+		if img.Checksum != md5Hex {
+			return fmt.Errorf("md5 mismatch: uploaded %s, Glance has %s", md5Hex, img.Checksum)
+		}
+		fmt.Fprintf(out, "Checksum verified (md5)\n")
+	default:
+		fmt.Fprintf(out, "Glance recorded no checksum, upload not verified\n")
+	}
+	return nil
+}
+
 // GetImageByName fetches the details of an image by its name.
 // The image names are not unique, so this function returns the first image if only one is found,
 // if multiple images or none are found, it returns an error.
@@ -217,6 +467,211 @@ func GetImageByID(computeURL, token, imageID string) (Image, error) {
 	return Image{}, fmt.Errorf("no image found for ID %s", imageID)
 }
 
+// GetImageDetails fetches a single image by ID.
+func GetImageDetails(computeURL, token, imageID string) (Image, error) {
+	var result Image
+
+	url := fmt.Sprintf("%s/v2/images/%s", computeURL, imageID)
+
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch image: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result, fmt.Errorf("image details request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	err = json.Unmarshal([]byte(apiResp.Response), &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse image details: %v", err)
+	}
+	return result, nil
+}
+
+// DownloadImageData streams the image data into w, starting at offset
+// bytes for resumed downloads. size is the full image size, for progress.
+func DownloadImageData(computeURL, token, imageID string, w io.Writer, offset, size int64) error {
+	url := fmt.Sprintf("%s/v2/images/%s/file", computeURL, imageID)
+
+	if viper.GetBool("debug") {
+		fmt.Printf("Attempting download from URL: %s\n", url)
+	}
+
+	return httpclient.DownloadBigFile(url, token, w, offset, size)
+}
+
+// SetImageProperties adds or replaces image properties. Core numeric and
+// boolean fields (min_disk, min_ram, protected) are converted from strings.
+func SetImageProperties(computeURL, token, imageID string, props map[string]string) error {
+	current, err := getImageRaw(computeURL, token, imageID)
+	if err != nil {
+		return err
+	}
+
+	var ops []imagePatchOp
+	for key, value := range props {
+		op := imagePatchOp{Op: "add", Path: "/" + key}
+		if _, exists := current[key]; exists {
+			op.Op = "replace"
+		}
+		if op.Value, err = imagePropertyValue(key, value); err != nil {
+			return err
+		}
+		ops = append(ops, op)
+	}
+
+	return patchImage(computeURL, token, imageID, ops)
+}
+
+// imagePropertyValue converts the value of the integer and boolean image
+// fields (min_disk, min_ram, protected); other properties stay strings.
+func imagePropertyValue(key, value string) (interface{}, error) {
+	switch key {
+	case "min_disk", "min_ram":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an integer, got %q", key, value)
+		}
+		return n, nil
+	case "protected":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", key, value)
+		}
+		return b, nil
+	}
+	return value, nil
+}
+
+// UnsetImageProperties removes custom properties from an image.
+func UnsetImageProperties(computeURL, token, imageID string, keys []string) error {
+	current, err := getImageRaw(computeURL, token, imageID)
+	if err != nil {
+		return err
+	}
+
+	var ops []imagePatchOp
+	for _, key := range keys {
+		if _, exists := current[key]; !exists {
+			return fmt.Errorf("image %s has no property %s", imageID, key)
+		}
+		ops = append(ops, imagePatchOp{Op: "remove", Path: "/" + key})
+	}
+
+	return patchImage(computeURL, token, imageID, ops)
+}
+
+// patchImage sends a JSON-patch update for an image.
+func patchImage(computeURL, token, imageID string, ops []imagePatchOp) error {
+	url := fmt.Sprintf("%s/v2/images/%s", computeURL, imageID)
+
+	apiResp, err := callJSON("PATCH", url, token, imagePatchContentType, ops)
+	if err != nil {
+		return fmt.Errorf("failed to update image: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return fmt.Errorf("update image request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
+
+// getImageRaw fetches an image with all of its properties as a generic map.
+func getImageRaw(computeURL, token, imageID string) (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+
+	url := fmt.Sprintf("%s/v2/images/%s", computeURL, imageID)
+
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch image: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result, fmt.Errorf("image details request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	err = json.Unmarshal([]byte(apiResp.Response), &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse image details: %v", err)
+	}
+	return result, nil
+}
+
+// ListImageMembers lists the projects an image is shared with.
+func ListImageMembers(computeURL, token, imageID string) (ImageMemberListResponse, error) {
+	var result ImageMemberListResponse
+
+	url := fmt.Sprintf("%s/v2/images/%s/members", computeURL, imageID)
+
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return result, fmt.Errorf("failed to list image members: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result, fmt.Errorf("list image members request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	err = json.Unmarshal([]byte(apiResp.Response), &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse image members response: %v", err)
+	}
+	return result, nil
+}
+
+// AddImageMember shares an image with a project. The image must have
+// "shared" visibility, and the project has to accept the share.
+func AddImageMember(computeURL, token, imageID, projectID string) (ImageMember, error) {
+	var result ImageMember
+
+	url := fmt.Sprintf("%s/v2/images/%s/members", computeURL, imageID)
+
+	request := map[string]string{"member": projectID}
+
+	apiResp, err := callPOST(url, token, request)
+	if err != nil {
+		return result, fmt.Errorf("failed to add image member: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result, fmt.Errorf("add image member request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	err = json.Unmarshal([]byte(apiResp.Response), &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse image member response: %v", err)
+	}
+	return result, nil
+}
+
+// RemoveImageMember stops sharing an image with a project.
+func RemoveImageMember(computeURL, token, imageID, projectID string) error {
+	url := fmt.Sprintf("%s/v2/images/%s/members/%s", computeURL, imageID, projectID)
+
+	apiResp, err := callDELETE(url, token)
+	if err != nil {
+		return fmt.Errorf("failed to remove image member: %v", err)
+	}
+	if apiResp.ResponseCode != 204 {
+		return fmt.Errorf("remove image member request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
+
+// UpdateImageMemberStatus accepts or rejects an image shared with projectID.
+// Must be called with a token scoped to that project.
+func UpdateImageMemberStatus(computeURL, token, imageID, projectID, status string) error {
+	url := fmt.Sprintf("%s/v2/images/%s/members/%s", computeURL, imageID, projectID)
+
+	request := map[string]string{"status": status}
+
+	apiResp, err := callJSON("PUT", url, token, "", request)
+	if err != nil {
+		return fmt.Errorf("failed to update image member: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return fmt.Errorf("update image member request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
+
 // GetImageSize fetches the size of an image by its ID.
 func GetImageSize(computeURL, token, imageID string) (int64, error) {
 	image, err := GetImageByID(computeURL, token, imageID)
@@ -211,3 +211,78 @@ func GetNetworkIDByName(baseURL, token, networkName string) (string, error) {
 
 	return foundNetworks[0].ID, nil
 }
+
+// CreateNetwork creates a network, owned by projectID if it's set (admin).
+func CreateNetwork(baseURL, token, name, projectID string) (Network, error) {
+	var wrapper struct {
+		Network Network `json:"network"`
+	}
+
+	request := map[string]map[string]interface{}{"network": {"name": name}}
+	if projectID != "" {
+		request["network"]["project_id"] = projectID
+	}
+
+	apiResp, err := callPOST(fmt.Sprintf("%s/v2.0/networks", baseURL), token, request)
+	if err != nil {
+		return wrapper.Network, fmt.Errorf("failed to create network: %v", err)
+	}
+	if apiResp.ResponseCode != 201 {
+		return wrapper.Network, fmt.Errorf("create network request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &wrapper); err != nil {
+		return wrapper.Network, fmt.Errorf("failed to parse network response: %v", err)
+	}
+	return wrapper.Network, nil
+}
+
+// CreateSubnet creates an IPv4 subnet with DHCP on a network and returns
+// its ID. Empty dnsServers leave DNS to the DHCP agent.
+func CreateSubnet(baseURL, token, networkID, name, cidr, projectID string, dnsServers []string) (string, error) {
+	var wrapper struct {
+		Subnet struct {
+			ID string `json:"id"`
+		} `json:"subnet"`
+	}
+
+	subnet := map[string]interface{}{
+		"network_id":  networkID,
+		"name":        name,
+		"cidr":        cidr,
+		"ip_version":  4,
+		"enable_dhcp": true,
+	}
+	if projectID != "" {
+		subnet["project_id"] = projectID
+	}
+	if len(dnsServers) > 0 {
+		subnet["dns_nameservers"] = dnsServers
+	}
+
+	apiResp, err := callPOST(fmt.Sprintf("%s/v2.0/subnets", baseURL), token, map[string]interface{}{"subnet": subnet})
+	if err != nil {
+		return "", fmt.Errorf("failed to create subnet: %v", err)
+	}
+	if apiResp.ResponseCode != 201 {
+		return "", fmt.Errorf("create subnet request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &wrapper); err != nil {
+		return "", fmt.Errorf("failed to parse subnet response: %v", err)
+	}
+	return wrapper.Subnet.ID, nil
+}
+
+// DeleteNetwork deletes a network and its subnets. It fails while ports
+// other than DHCP ports remain on it.
+func DeleteNetwork(baseURL, token, networkID string) error {
+	apiResp, err := callDELETE(fmt.Sprintf("%s/v2.0/networks/%s", baseURL, networkID), token)
+	if err != nil {
+		return fmt.Errorf("failed to delete network: %v", err)
+	}
+	if apiResp.ResponseCode != 204 {
+		return fmt.Errorf("delete network request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 )
 
 // ProjectListResponse is the JSON structure returned by GET /v3/projects
@@ -65,7 +66,7 @@ func GetProjectNameByID(identityUrl, token, projectID string) (string, error) {
 func GetProjectIDByName(identityUrl, token, projectName string) (string, error) {
 	var result ProjectListResponse
 
-	apiResp, err := callGET(fmt.Sprintf("%s/projects?name=%s", identityUrl, projectName), token)
+	apiResp, err := callGET(fmt.Sprintf("%s/projects?name=%s", identityUrl, url.QueryEscape(projectName)), token)
 	if err != nil {
 		return "", fmt.Errorf("failed to get project ID: %v", err)
 	}
@@ -85,3 +86,108 @@ func GetProjectIDByName(identityUrl, token, projectName string) (string, error)
 
 	return result.Projects[0].ID, nil
 }
+
+// ProjectDetail is a Keystone project.
+type ProjectDetail struct {
+	ID          string `json:"id"`
+	DomainID    string `json:"domain_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+}
+
+// GetProject calls GET /v3/projects/{id}.
+func GetProject(identityUrl, token, projectID string) (ProjectDetail, error) {
+	var result struct {
+		Project ProjectDetail `json:"project"`
+	}
+
+	apiResp, err := callGET(fmt.Sprintf("%s/projects/%s", identityUrl, url.PathEscape(projectID)), token)
+	if err != nil {
+		return result.Project, fmt.Errorf("failed to get project: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result.Project, fmt.Errorf("get project failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return result.Project, fmt.Errorf("error unmarshalling project: %v", err)
+	}
+	return result.Project, nil
+}
+
+// FindProjectsByName returns every project named name, only those in
+// domainID if it's set.
+func FindProjectsByName(identityUrl, token, name, domainID string) ([]ProjectDetail, error) {
+	var result struct {
+		Projects []ProjectDetail `json:"projects"`
+	}
+
+	query := url.Values{"name": {name}}
+	if domainID != "" {
+		query.Set("domain_id", domainID)
+	}
+
+	apiResp, err := callGET(fmt.Sprintf("%s/projects?%s", identityUrl, query.Encode()), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find projects: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("find projects failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("error unmarshalling project list: %v", err)
+	}
+
+	// Keystone matches names exactly, but don't rely on it here
+	var matches []ProjectDetail
+	for _, p := range result.Projects {
+		if p.Name == name {
+			matches = append(matches, p)
+		}
+	}
+	return matches, nil
+}
+
+// CreateProject creates an enabled project in a domain.
+func CreateProject(identityUrl, token, name, domainID, description string) (ProjectDetail, error) {
+	var result struct {
+		Project ProjectDetail `json:"project"`
+	}
+
+	request := map[string]map[string]interface{}{
+		"project": {
+			"name":        name,
+			"domain_id":   domainID,
+			"description": description,
+			"enabled":     true,
+		},
+	}
+
+	apiResp, err := callPOST(fmt.Sprintf("%s/projects", identityUrl), token, request)
+	if err != nil {
+		return result.Project, fmt.Errorf("failed to create project: %v", err)
+	}
+	if apiResp.ResponseCode != 201 {
+		return result.Project, fmt.Errorf("create project failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return result.Project, fmt.Errorf("error unmarshalling project: %v", err)
+	}
+	return result.Project, nil
+}
+
+// DeleteProject deletes a project. Resources it owns in other services
+// are left behind.
+func DeleteProject(identityUrl, token, projectID string) error {
+	apiResp, err := callDELETE(fmt.Sprintf("%s/projects/%s", identityUrl, projectID), token)
+	if err != nil {
+		return fmt.Errorf("failed to delete project: %v", err)
+	}
+	if apiResp.ResponseCode != 204 {
+		return fmt.Errorf("delete project failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
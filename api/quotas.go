@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// QuotaUsage is the limit and usage of one quota resource. A limit of -1
+// means unlimited.
+type QuotaUsage struct {
+	Limit    int `json:"limit"`
+	InUse    int `json:"in_use"`
+	Reserved int `json:"reserved"`
+}
+
+// Free returns how much of the resource is left, or -1 if it's unlimited
+func (q QuotaUsage) Free() int {
+	if q.Limit < 0 {
+		return -1
+	}
+	free := q.Limit - q.InUse - q.Reserved
+	if free < 0 {
+		return 0
+	}
+	return free
+}
+
+// GetVolumeQuotaUsage fetches the Cinder quotas of a project with their
+// usage, keyed by resource (volumes, gigabytes, gigabytes_<type>, ...).
+func GetVolumeQuotaUsage(storageURL, token, projectID string) (map[string]QuotaUsage, error) {
+	var wrapper struct {
+		QuotaSet map[string]json.RawMessage `json:"quota_set"`
+	}
+
+	url := fmt.Sprintf("%s/os-quota-sets/%s?usage=true", storageURL, projectID)
+
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch volume quotas: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("volume quota request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &wrapper); err != nil {
+		return nil, fmt.Errorf("failed to parse volume quota response: %v", err)
+	}
+
+	// the set also carries the project "id", which isn't a resource
+	result := make(map[string]QuotaUsage)
+	for key, raw := range wrapper.QuotaSet {
+		var usage QuotaUsage
+		if err := json.Unmarshal(raw, &usage); err != nil {
+			continue
+		}
+		result[key] = usage
+	}
+	return result, nil
+}
+
+// GetComputeLimits fetches the Nova absolute limits of the current
+// project, or of projectID if set (admin only), keyed by instances, cores
+// and ram (MB).
+func GetComputeLimits(computeURL, token, projectID string) (map[string]QuotaUsage, error) {
+	var result struct {
+		Limits struct {
+			Absolute map[string]int `json:"absolute"`
+		} `json:"limits"`
+	}
+
+	url := fmt.Sprintf("%s/limits", computeURL)
+	if projectID != "" {
+		url += "?tenant_id=" + projectID
+	}
+
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch compute limits: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("compute limits request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse compute limits response: %v", err)
+	}
+
+	absolute := result.Limits.Absolute
+	return map[string]QuotaUsage{
+		"instances": {Limit: absolute["maxTotalInstances"], InUse: absolute["totalInstancesUsed"]},
+		"cores":     {Limit: absolute["maxTotalCores"], InUse: absolute["totalCoresUsed"]},
+		"ram":       {Limit: absolute["maxTotalRAMSize"], InUse: absolute["totalRAMUsed"]},
+	}, nil
+}
+
+// GetNetworkQuotaUsage fetches the Neutron quotas of a project with their
+// usage, keyed by resource (port, floatingip, network, router, ...).
+func GetNetworkQuotaUsage(networkURL, token, projectID string) (map[string]QuotaUsage, error) {
+	var wrapper struct {
+		Quota map[string]struct {
+			Limit    int `json:"limit"`
+			Used     int `json:"used"`
+			Reserved int `json:"reserved"`
+		} `json:"quota"`
+	}
+
+	url := fmt.Sprintf("%s/v2.0/quotas/%s/details.json", networkURL, projectID)
+
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch network quotas: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("network quota request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &wrapper); err != nil {
+		return nil, fmt.Errorf("failed to parse network quota response: %v", err)
+	}
+
+	result := make(map[string]QuotaUsage)
+	for key, q := range wrapper.Quota {
+		result[key] = QuotaUsage{Limit: q.Limit, InUse: q.Used, Reserved: q.Reserved}
+	}
+	return result, nil
+}
+
+// SetComputeQuotas sets Nova quotas of a project, e.g. cores, ram (MB)
+// and instances.
+func SetComputeQuotas(computeURL, token, projectID string, quotas map[string]int) error {
+	url := fmt.Sprintf("%s/os-quota-sets/%s", computeURL, projectID)
+	return putQuotas(url, token, "quota_set", quotas, "compute")
+}
+
+// SetVolumeQuotas sets Cinder quotas of a project, e.g. volumes,
+// gigabytes and gigabytes_<type>.
+func SetVolumeQuotas(storageURL, token, projectID string, quotas map[string]int) error {
+	url := fmt.Sprintf("%s/os-quota-sets/%s", storageURL, projectID)
+	return putQuotas(url, token, "quota_set", quotas, "volume")
+}
+
+// SetNetworkQuotas sets Neutron quotas of a project, e.g. port, network,
+// router and floatingip.
+func SetNetworkQuotas(networkURL, token, projectID string, quotas map[string]int) error {
+	url := fmt.Sprintf("%s/v2.0/quotas/%s", networkURL, projectID)
+	return putQuotas(url, token, "quota", quotas, "network")
+}
+
+func putQuotas(url, token, key string, quotas map[string]int, service string) error {
+	request := map[string]map[string]int{key: quotas}
+
+	apiResp, err := callJSON("PUT", url, token, "", request)
+	if err != nil {
+		return fmt.Errorf("failed to set %s quotas: %v", service, err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return fmt.Errorf("set %s quotas request failed [%d]: %s", service, apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
+
+// ResetQuotas reverts the compute, volume and network quotas of a
+// project to the defaults, e.g. before the project is deleted.
+func ResetQuotas(computeURL, storageURL, networkURL, token, projectID string) error {
+	for _, url := range []string{
+		fmt.Sprintf("%s/os-quota-sets/%s", computeURL, projectID),
+		fmt.Sprintf("%s/os-quota-sets/%s", storageURL, projectID),
+		fmt.Sprintf("%s/v2.0/quotas/%s", networkURL, projectID),
+	} {
+		apiResp, err := callDELETE(url, token)
+		if err != nil {
+			return fmt.Errorf("failed to reset quotas: %v", err)
+		}
+		if apiResp.ResponseCode >= 300 && apiResp.ResponseCode != 404 {
+			return fmt.Errorf("reset quotas request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Role is a Keystone role.
+type Role struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	DomainID string `json:"domain_id,omitempty"`
+}
+
+// GetRoleIDByName returns the ID of the global role named roleName.
+func GetRoleIDByName(identityUrl, token, roleName string) (string, error) {
+	var result struct {
+		Roles []Role `json:"roles"`
+	}
+
+	apiResp, err := callGET(fmt.Sprintf("%s/roles?name=%s", identityUrl, url.QueryEscape(roleName)), token)
+	if err != nil {
+		return "", fmt.Errorf("failed to get role ID: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return "", fmt.Errorf("get role ID failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return "", fmt.Errorf("error unmarshalling role list: %v", err)
+	}
+	if len(result.Roles) == 0 {
+		return "", fmt.Errorf("no role found for name %s", roleName)
+	}
+	return result.Roles[0].ID, nil
+}
+
+// RoleAssignment is one entry of GET /v3/role_assignments; names are
+// filled in when include_names is set. Exactly one of User and Group, and
+// of Project and Domain in Scope, is set.
+type RoleAssignment struct {
+	Role struct {
+		ID   string `json:"id"`
+		Name string `json:"name,omitempty"`
+	} `json:"role"`
+	User  *AssignmentActor `json:"user,omitempty"`
+	Group *AssignmentActor `json:"group,omitempty"`
+	Scope struct {
+		Project *AssignmentActor `json:"project,omitempty"`
+		Domain  *AssignmentActor `json:"domain,omitempty"`
+	} `json:"scope"`
+}
+
+// AssignmentActor is the user, group, project or domain of a role
+// assignment.
+type AssignmentActor struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Domain *struct {
+		ID   string `json:"id"`
+		Name string `json:"name,omitempty"`
+	} `json:"domain,omitempty"`
+}
+
+// ListRoles calls GET /v3/roles.
+func ListRoles(identityUrl, token string) ([]Role, error) {
+	var result struct {
+		Roles []Role `json:"roles"`
+	}
+
+	apiResp, err := callGET(fmt.Sprintf("%s/roles", identityUrl), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("list roles failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("error unmarshalling role list: %v", err)
+	}
+	return result.Roles, nil
+}
+
+// ListRoleAssignments calls GET /v3/role_assignments with filters such as
+// user.id, scope.project.id, scope.domain.id, effective or include_names.
+func ListRoleAssignments(identityUrl, token string, queryParams map[string]string) ([]RoleAssignment, error) {
+	var result struct {
+		RoleAssignments []RoleAssignment `json:"role_assignments"`
+	}
+
+	query := url.Values{}
+	for k, v := range queryParams {
+		query.Set(k, v)
+	}
+	reqURL := fmt.Sprintf("%s/role_assignments", identityUrl)
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	apiResp, err := callGET(reqURL, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role assignments: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("list role assignments failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("error unmarshalling role assignments: %v", err)
+	}
+	return result.RoleAssignments, nil
+}
+
+// AssignProjectRole grants a user a role on a project.
+func AssignProjectRole(identityUrl, token, projectID, userID, roleID string) error {
+	return roleGrant(identityUrl, token, "projects", projectID, userID, roleID, false)
+}
+
+// RevokeProjectRole removes a user's role on a project.
+func RevokeProjectRole(identityUrl, token, projectID, userID, roleID string) error {
+	return roleGrant(identityUrl, token, "projects", projectID, userID, roleID, true)
+}
+
+// AssignDomainRole grants a user a role on a domain.
+func AssignDomainRole(identityUrl, token, domainID, userID, roleID string) error {
+	return roleGrant(identityUrl, token, "domains", domainID, userID, roleID, false)
+}
+
+// RevokeDomainRole removes a user's role on a domain.
+func RevokeDomainRole(identityUrl, token, domainID, userID, roleID string) error {
+	return roleGrant(identityUrl, token, "domains", domainID, userID, roleID, true)
+}
+
+// roleGrant adds a user's role on a project or domain, or removes it if
+// revoke is set
+func roleGrant(identityUrl, token, scope, scopeID, userID, roleID string, revoke bool) error {
+	url := fmt.Sprintf("%s/%s/%s/users/%s/roles/%s", identityUrl, scope, scopeID, userID, roleID)
+
+	action := "assign"
+	call := func() (ApiResponse, error) { return callJSON("PUT", url, token, "", struct{}{}) }
+	if revoke {
+		action = "revoke"
+		call = func() (ApiResponse, error) { return callDELETE(url, token) }
+	}
+
+	apiResp, err := call()
+	if err != nil {
+		return fmt.Errorf("failed to %s role: %v", action, err)
+	}
+	if apiResp.ResponseCode != 204 {
+		return fmt.Errorf("%s role failed [%d]: %s", action, apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
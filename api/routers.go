@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Router is a Neutron router.
+type Router struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	ProjectID           string `json:"project_id"`
+	Status              string `json:"status"`
+	ExternalGatewayInfo *struct {
+		NetworkID string `json:"network_id"`
+	} `json:"external_gateway_info"`
+}
+
+// CreateRouter creates a router, owned by projectID if it's set (admin),
+// with its gateway on externalNetworkID if that's set.
+func CreateRouter(baseURL, token, name, projectID, externalNetworkID string) (Router, error) {
+	var wrapper struct {
+		Router Router `json:"router"`
+	}
+
+	router := map[string]interface{}{"name": name}
+	if projectID != "" {
+		router["project_id"] = projectID
+	}
+	if externalNetworkID != "" {
+		router["external_gateway_info"] = map[string]string{"network_id": externalNetworkID}
+	}
+
+	apiResp, err := callPOST(fmt.Sprintf("%s/v2.0/routers", baseURL), token, map[string]interface{}{"router": router})
+	if err != nil {
+		return wrapper.Router, fmt.Errorf("failed to create router: %v", err)
+	}
+	if apiResp.ResponseCode != 201 {
+		return wrapper.Router, fmt.Errorf("create router request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &wrapper); err != nil {
+		return wrapper.Router, fmt.Errorf("failed to parse router response: %v", err)
+	}
+	return wrapper.Router, nil
+}
+
+// ListRouters fetches routers, filtered by queryParams (e.g. project_id).
+func ListRouters(baseURL, token string, queryParams map[string]string) ([]Router, error) {
+	var result struct {
+		Routers []Router `json:"routers"`
+	}
+
+	apiResp, err := callGET(neutronListURL(baseURL, "routers", queryParams), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch routers: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("list routers request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse routers response: %v", err)
+	}
+	return result.Routers, nil
+}
+
+// AddRouterInterface connects a subnet to a router.
+func AddRouterInterface(baseURL, token, routerID, subnetID string) error {
+	return routerInterface(baseURL, token, routerID, "add_router_interface", map[string]string{"subnet_id": subnetID})
+}
+
+// RemoveRouterInterface disconnects a router interface port from a router.
+func RemoveRouterInterface(baseURL, token, routerID, portID string) error {
+	return routerInterface(baseURL, token, routerID, "remove_router_interface", map[string]string{"port_id": portID})
+}
+
+func routerInterface(baseURL, token, routerID, action string, body map[string]string) error {
+	url := fmt.Sprintf("%s/v2.0/routers/%s/%s", baseURL, routerID, action)
+
+	apiResp, err := callJSON("PUT", url, token, "", body)
+	if err != nil {
+		return fmt.Errorf("failed to send %s request: %v", action, err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return fmt.Errorf("%s request failed [%d]: %s", action, apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
+
+// DeleteRouter deletes a router without interfaces.
+func DeleteRouter(baseURL, token, routerID string) error {
+	return neutronDelete(baseURL, token, "routers", routerID)
+}
+
+// FloatingIP is a Neutron floating IP.
+type FloatingIP struct {
+	ID                string `json:"id"`
+	FloatingIPAddress string `json:"floating_ip_address"`
+	ProjectID         string `json:"project_id"`
+	PortID            string `json:"port_id"`
+}
+
+// ListFloatingIPs fetches floating IPs, filtered by queryParams (e.g. project_id).
+func ListFloatingIPs(baseURL, token string, queryParams map[string]string) ([]FloatingIP, error) {
+	var result struct {
+		FloatingIPs []FloatingIP `json:"floatingips"`
+	}
+
+	apiResp, err := callGET(neutronListURL(baseURL, "floatingips", queryParams), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch floating IPs: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("list floating IPs request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse floating IPs response: %v", err)
+	}
+	return result.FloatingIPs, nil
+}
+
+// DeleteFloatingIP releases a floating IP.
+func DeleteFloatingIP(baseURL, token, floatingIPID string) error {
+	return neutronDelete(baseURL, token, "floatingips", floatingIPID)
+}
+
+// ListSecurityGroups fetches security groups, filtered by queryParams (e.g. project_id).
+func ListSecurityGroups(baseURL, token string, queryParams map[string]string) ([]SecurityGroup, error) {
+	var result struct {
+		SecurityGroups []SecurityGroup `json:"security_groups"`
+	}
+
+	apiResp, err := callGET(neutronListURL(baseURL, "security-groups", queryParams), token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch security groups: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("list security groups request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse security groups response: %v", err)
+	}
+	return result.SecurityGroups, nil
+}
+
+// DeleteSecurityGroup deletes a security group no port uses.
+func DeleteSecurityGroup(baseURL, token, securityGroupID string) error {
+	return neutronDelete(baseURL, token, "security-groups", securityGroupID)
+}
+
+// neutronListURL builds the URL listing a Neutron resource with filters
+func neutronListURL(baseURL, resource string, queryParams map[string]string) string {
+	listURL := fmt.Sprintf("%s/v2.0/%s", baseURL, resource)
+	if len(queryParams) > 0 {
+		params := url.Values{}
+		for key, value := range queryParams {
+			params.Add(key, value)
+		}
+		listURL += "?" + params.Encode()
+	}
+	return listURL
+}
+
+// neutronDelete deletes one Neutron resource by ID
+func neutronDelete(baseURL, token, resource, id string) error {
+	apiResp, err := callDELETE(fmt.Sprintf("%s/v2.0/%s/%s", baseURL, resource, id), token)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %s: %v", resource, id, err)
+	}
+	if apiResp.ResponseCode != 204 {
+		return fmt.Errorf("delete %s %s request failed [%d]: %s", resource, id, apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Snapshot represents a block storage volume snapshot.
+type Snapshot struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Size        int    `json:"size"`
+	Status      string `json:"status"`
+	VolumeID    string `json:"volume_id"`
+	Description string `json:"description"`
+}
+
+// SnapshotListResponse represents the response for listing snapshots.
+type SnapshotListResponse struct {
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// ListSnapshots fetches the list of volume snapshots.
+func ListSnapshots(storageURL, token string, queryParams map[string]string) (SnapshotListResponse, error) {
+	var result SnapshotListResponse
+
+	url := fmt.Sprintf("%s/snapshots/detail", storageURL)
+	if len(queryParams) > 0 {
+		url += "?"
+		for key, value := range queryParams {
+			url += fmt.Sprintf("%s=%s&", key, value)
+		}
+		url = strings.TrimSuffix(url, "&")
+	}
+
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch snapshots: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result, fmt.Errorf("list snapshots request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	err = json.Unmarshal([]byte(apiResp.Response), &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse snapshots response: %v", err)
+	}
+	return result, nil
+}
+
+// GetSnapshotByNameOrID returns the single snapshot whose ID matches
+// nameOrID, or whose name contains it.
+func GetSnapshotByNameOrID(storageURL, token, nameOrID string) (Snapshot, error) {
+	snapshots, err := ListSnapshots(storageURL, token, nil)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	var found []Snapshot
+	for _, snap := range snapshots.Snapshots {
+		if snap.ID == nameOrID {
+			return snap, nil
+		}
+		if strings.Contains(snap.Name, nameOrID) {
+			found = append(found, snap)
+		}
+	}
+
+	if len(found) == 0 {
+		return Snapshot{}, fmt.Errorf("no snapshots found for %s", nameOrID)
+	}
+	if len(found) > 1 {
+		return Snapshot{}, fmt.Errorf("multiple snapshots found for name %s", nameOrID)
+	}
+	return found[0], nil
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// UserDetail is a Keystone user.
+type UserDetail struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	DomainID          string `json:"domain_id"`
+	Enabled           bool   `json:"enabled"`
+	Description       string `json:"description,omitempty"`
+	Email             string `json:"email,omitempty"`
+	DefaultProjectID  string `json:"default_project_id,omitempty"`
+	PasswordExpiresAt string `json:"password_expires_at,omitempty"`
+}
+
+// CreateUserRequest is the body of POST /v3/users.
+type CreateUserRequest struct {
+	Name             string `json:"name"`
+	DomainID         string `json:"domain_id,omitempty"`
+	Password         string `json:"password,omitempty"`
+	Description      string `json:"description,omitempty"`
+	Email            string `json:"email,omitempty"`
+	DefaultProjectID string `json:"default_project_id,omitempty"`
+	Enabled          bool   `json:"enabled"`
+}
+
+// ListUsers calls GET /v3/users with optional filters such as domain_id,
+// name or enabled.
+func ListUsers(identityUrl, token string, queryParams map[string]string) ([]UserDetail, error) {
+	var result struct {
+		Users []UserDetail `json:"users"`
+	}
+
+	query := url.Values{}
+	for k, v := range queryParams {
+		query.Set(k, v)
+	}
+	reqURL := fmt.Sprintf("%s/users", identityUrl)
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+
+	apiResp, err := callGET(reqURL, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return nil, fmt.Errorf("list users failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return nil, fmt.Errorf("error unmarshalling user list: %v", err)
+	}
+	return result.Users, nil
+}
+
+// CreateUser calls POST /v3/users.
+func CreateUser(identityUrl, token string, req CreateUserRequest) (UserDetail, error) {
+	var result struct {
+		User UserDetail `json:"user"`
+	}
+
+	apiResp, err := callPOST(fmt.Sprintf("%s/users", identityUrl), token, map[string]interface{}{"user": req})
+	if err != nil {
+		return result.User, fmt.Errorf("failed to create user: %v", err)
+	}
+	if apiResp.ResponseCode != 201 {
+		return result.User, fmt.Errorf("create user failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return result.User, fmt.Errorf("error unmarshalling user: %v", err)
+	}
+	return result.User, nil
+}
+
+// UpdateUser calls PATCH /v3/users/{id} with the attributes to change,
+// e.g. enabled or password (an admin reset, no old password needed).
+func UpdateUser(identityUrl, token, userID string, attrs map[string]interface{}) (UserDetail, error) {
+	var result struct {
+		User UserDetail `json:"user"`
+	}
+
+	apiResp, err := callJSON("PATCH", fmt.Sprintf("%s/users/%s", identityUrl, userID), token, "", map[string]interface{}{"user": attrs})
+	if err != nil {
+		return result.User, fmt.Errorf("failed to update user: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result.User, fmt.Errorf("update user failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return result.User, fmt.Errorf("error unmarshalling user: %v", err)
+	}
+	return result.User, nil
+}
+
+// GetUserIDByName returns the ID of the user named userName, in domainID
+// if it's set.
+func GetUserIDByName(identityUrl, token, userName, domainID string) (string, error) {
+	var result struct {
+		Users []UserDetail `json:"users"`
+	}
+
+	query := url.Values{"name": {userName}}
+	if domainID != "" {
+		query.Set("domain_id", domainID)
+	}
+
+	apiResp, err := callGET(fmt.Sprintf("%s/users?%s", identityUrl, query.Encode()), token)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user ID: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return "", fmt.Errorf("get user ID failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return "", fmt.Errorf("error unmarshalling user list: %v", err)
+	}
+	if len(result.Users) == 0 {
+		return "", fmt.Errorf("no user found for name %s", userName)
+	}
+	if len(result.Users) > 1 {
+		return "", fmt.Errorf("multiple users found for name %s; give the user ID or a domain", userName)
+	}
+	return result.Users[0].ID, nil
+}
+
+// GetUser calls GET /v3/users/{id}.
+func GetUser(identityUrl, token, userID string) (UserDetail, error) {
+	var result struct {
+		User UserDetail `json:"user"`
+	}
+
+	apiResp, err := callGET(fmt.Sprintf("%s/users/%s", identityUrl, userID), token)
+	if err != nil {
+		return result.User, fmt.Errorf("failed to get user: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result.User, fmt.Errorf("get user failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	if err := json.Unmarshal([]byte(apiResp.Response), &result); err != nil {
+		return result.User, fmt.Errorf("error unmarshalling user: %v", err)
+	}
+	return result.User, nil
+}
+
+// ChangePassword calls POST /v3/users/{id}/password, which needs the
+// user's current password but no token, so an expired password can be
+// changed. Keystone revokes the user's tokens on success.
+func ChangePassword(identityUrl, userID, originalPassword, newPassword string) error {
+	body := map[string]interface{}{
+		"user": map[string]string{
+			"original_password": originalPassword,
+			"password":          newPassword,
+		},
+	}
+
+	apiResp, err := callPOST(fmt.Sprintf("%s/users/%s/password", identityUrl, userID), "", body)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %v", err)
+	}
+	if apiResp.ResponseCode != 204 {
+		return fmt.Errorf("change password failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+	return nil
+}
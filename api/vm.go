@@ -155,6 +155,18 @@ type RebootRequestPayload struct {
 	} `json:"reboot"`
 }
 
+// RescueRequest is used for the "rescue" server action
+type RescueRequest struct {
+	Rescue struct {
+		RescueImageRef string `json:"rescue_image_ref,omitempty"`
+	} `json:"rescue"`
+}
+
+// UnrescueRequest is used for the "unrescue" server action
+type UnrescueRequest struct {
+	Unrescue *struct{} `json:"unrescue"`
+}
+
 // DetachVolume sends a request to detach a volume from a VM.
 func DetachVolume(computeURL, token, vmID, volumeID string) error {
 	url := fmt.Sprintf("%s/servers/%s/os-volume_attachments/%s", computeURL, vmID, volumeID)
@@ -390,6 +402,46 @@ func RebootVM(computeURL, token, vmID string, rebootType string) error {
 	}
 }
 
+// RescueVM boots a VM into rescue mode from imageRef (or its own image if
+// empty) with the original disks attached, and returns the rescue admin password.
+// Volume-backed VMs require compute microversion 2.87.
+func RescueVM(computeURL, token, vmID, imageRef string) (string, error) {
+	url := fmt.Sprintf("%s/servers/%s/action", computeURL, vmID)
+
+	var request RescueRequest
+	request.Rescue.RescueImageRef = imageRef
+
+	headers := map[string]string{"X-OpenStack-Nova-API-Version": "2.87"}
+	resp, err := callPOSTWithHeaders(url, token, request, headers)
+	if err != nil {
+		return "", fmt.Errorf("failed to send rescue request: %v", err)
+	}
+	if resp.ResponseCode != 200 {
+		return "", fmt.Errorf("rescue request failed [%d]: %s", resp.ResponseCode, resp.Response)
+	}
+
+	var result struct {
+		AdminPass string `json:"adminPass"`
+	}
+	_ = json.Unmarshal([]byte(resp.Response), &result)
+
+	return result.AdminPass, nil
+}
+
+// UnrescueVM returns a VM from rescue mode to normal operation.
+func UnrescueVM(computeURL, token, vmID string) error {
+	url := fmt.Sprintf("%s/servers/%s/action", computeURL, vmID)
+
+	resp, err := callPOST(url, token, UnrescueRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to send unrescue request: %v", err)
+	}
+	if resp.ResponseCode != 202 {
+		return fmt.Errorf("unrescue request failed [%d]: %s", resp.ResponseCode, resp.Response)
+	}
+	return nil
+}
+
 // WaitForStatus waits for a VM to reach a given status or returns error on timeout/error
 func WaitForStatus(computeURL, token, vmID string, targetStatus string) (VMDetail, error) {
 	maxAttempts := 30
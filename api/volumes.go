@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -13,6 +14,7 @@ type Volume struct {
 	Size        int    `json:"size"`
 	Description string `json:"description"`
 	Status      string `json:"status"`
+	VolumeType  string `json:"volume_type"`
 }
 
 // VolumeListResponse represents the response for listing volumes.
@@ -28,6 +30,7 @@ type CreateVolumeRequest struct {
 		Description string `json:"description,omitempty"`
 		ImageRef    string `json:"imageRef,omitempty"`
 		VolumeType  string `json:"volume_type,omitempty"`
+		SourceVolID string `json:"source_volid,omitempty"`
 	} `json:"volume"`
 }
 
@@ -42,6 +45,49 @@ type SetBootableRequest struct {
 	} `json:"os-set_bootable"`
 }
 
+// ExtendVolumeRequest represents the payload for the os-extend action.
+type ExtendVolumeRequest struct {
+	OsExtend struct {
+		NewSize int `json:"new_size"`
+	} `json:"os-extend"`
+}
+
+// RetypeVolumeRequest represents the payload for the os-retype action.
+type RetypeVolumeRequest struct {
+	OsRetype struct {
+		NewType         string `json:"new_type"`
+		MigrationPolicy string `json:"migration_policy,omitempty"` // never, on-demand
+	} `json:"os-retype"`
+}
+
+// VolumeTransfer represents a volume transfer between projects.
+type VolumeTransfer struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	VolumeID string `json:"volume_id"`
+	AuthKey  string `json:"auth_key,omitempty"` // only returned on create
+}
+
+// CreateVolumeTransferRequest represents the payload for creating a transfer.
+type CreateVolumeTransferRequest struct {
+	Transfer struct {
+		VolumeID string `json:"volume_id"`
+		Name     string `json:"name,omitempty"`
+	} `json:"transfer"`
+}
+
+// AcceptVolumeTransferRequest represents the payload for accepting a transfer.
+type AcceptVolumeTransferRequest struct {
+	Accept struct {
+		AuthKey string `json:"auth_key"`
+	} `json:"accept"`
+}
+
+// VolumeTransferResponse represents the response for transfer create/accept.
+type VolumeTransferResponse struct {
+	Transfer VolumeTransfer `json:"transfer"`
+}
+
 // SetVolumeBootable sets a volume’s bootable flag
 func SetVolumeBootable(storageURL, token, volumeID string, bootable bool) error {
 	url := fmt.Sprintf("%s/volumes/%s/action", storageURL, volumeID)
@@ -122,6 +168,141 @@ func DeleteVolume(storageURL, token, volumeID string) error {
 	return nil
 }
 
+// GetVolumeDetails fetches a single volume by ID.
+func GetVolumeDetails(storageURL, token, volumeID string) (Volume, error) {
+	var wrapper struct {
+		Volume Volume `json:"volume"`
+	}
+
+	url := fmt.Sprintf("%s/volumes/%s", storageURL, volumeID)
+
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return wrapper.Volume, fmt.Errorf("failed to fetch volume details: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return wrapper.Volume, fmt.Errorf("volume details request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	err = json.Unmarshal([]byte(apiResp.Response), &wrapper)
+	if err != nil {
+		return wrapper.Volume, fmt.Errorf("failed to parse volume details: %v", err)
+	}
+	return wrapper.Volume, nil
+}
+
+// GetVolumeIDByName fetches the ID of a volume by its name.
+func GetVolumeIDByName(storageURL, token, volumeName string) (string, error) {
+	volumes, err := ListVolumes(storageURL, token, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var foundVolumes []Volume
+	for _, volume := range volumes.Volumes {
+		if strings.Contains(volume.Name, volumeName) {
+			foundVolumes = append(foundVolumes, volume)
+		}
+	}
+
+	if len(foundVolumes) == 0 {
+		return "", fmt.Errorf("no volumes found for name %s", volumeName)
+	}
+	if len(foundVolumes) > 1 {
+		return "", fmt.Errorf("multiple volumes found for name %s", volumeName)
+	}
+
+	return foundVolumes[0].ID, nil
+}
+
+// ExtendVolume grows a volume to newSize GB. Attached (in-use) volumes
+// are extended online, which requires volume API microversion 3.42.
+func ExtendVolume(storageURL, token, volumeID string, newSize int) error {
+	url := fmt.Sprintf("%s/volumes/%s/action", storageURL, volumeID)
+
+	request := ExtendVolumeRequest{}
+	request.OsExtend.NewSize = newSize
+
+	headers := map[string]string{"OpenStack-API-Version": "volume 3.42"}
+	resp, err := callPOSTWithHeaders(url, token, request, headers)
+	if err != nil {
+		return fmt.Errorf("failed to extend volume: %v", err)
+	}
+	if resp.ResponseCode != 202 {
+		return fmt.Errorf("failed to extend volume [%d]: %s", resp.ResponseCode, resp.Response)
+	}
+	return nil
+}
+
+// RetypeVolume changes the volume type, optionally migrating the data
+// if the new type lives on a different backend.
+func RetypeVolume(storageURL, token, volumeID, newType, migrationPolicy string) error {
+	url := fmt.Sprintf("%s/volumes/%s/action", storageURL, volumeID)
+
+	request := RetypeVolumeRequest{}
+	request.OsRetype.NewType = newType
+	request.OsRetype.MigrationPolicy = migrationPolicy
+
+	resp, err := callPOST(url, token, request)
+	if err != nil {
+		return fmt.Errorf("failed to retype volume: %v", err)
+	}
+	if resp.ResponseCode != 202 {
+		return fmt.Errorf("failed to retype volume [%d]: %s", resp.ResponseCode, resp.Response)
+	}
+	return nil
+}
+
+// CreateVolumeTransfer starts a transfer of a volume to another project.
+// The returned auth key must be handed to the receiving project.
+func CreateVolumeTransfer(storageURL, token, volumeID, name string) (VolumeTransferResponse, error) {
+	var result VolumeTransferResponse
+
+	url := fmt.Sprintf("%s/os-volume-transfer", storageURL)
+
+	request := CreateVolumeTransferRequest{}
+	request.Transfer.VolumeID = volumeID
+	request.Transfer.Name = name
+
+	apiResp, err := callPOST(url, token, request)
+	if err != nil {
+		return result, fmt.Errorf("failed to create volume transfer: %v", err)
+	}
+	if apiResp.ResponseCode != 202 {
+		return result, fmt.Errorf("volume transfer request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	err = json.Unmarshal([]byte(apiResp.Response), &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse volume transfer response: %v", err)
+	}
+	return result, nil
+}
+
+// AcceptVolumeTransfer accepts a pending transfer into the current project.
+func AcceptVolumeTransfer(storageURL, token, transferID, authKey string) (VolumeTransferResponse, error) {
+	var result VolumeTransferResponse
+
+	url := fmt.Sprintf("%s/os-volume-transfer/%s/accept", storageURL, transferID)
+
+	request := AcceptVolumeTransferRequest{}
+	request.Accept.AuthKey = authKey
+
+	apiResp, err := callPOST(url, token, request)
+	if err != nil {
+		return result, fmt.Errorf("failed to accept volume transfer: %v", err)
+	}
+	if apiResp.ResponseCode != 202 {
+		return result, fmt.Errorf("accept volume transfer failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	err = json.Unmarshal([]byte(apiResp.Response), &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse accept transfer response: %v", err)
+	}
+	return result, nil
+}
+
 // WaitForVolumeStatus polls volume status until it matches target or times out
 func WaitForVolumeStatus(storageURL, token, volumeID, targetStatus string) error {
 	maxAttempts := 30 // ~5 minutes with 10s intervals
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// VolumeType represents a Cinder volume type (storage policy).
+type VolumeType struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	IsPublic    bool              `json:"os-volume-type-access:is_public"`
+	ExtraSpecs  map[string]string `json:"extra_specs,omitempty"`
+}
+
+// VolumeTypeListResponse represents the response for listing volume types.
+type VolumeTypeListResponse struct {
+	VolumeTypes []VolumeType `json:"volume_types"`
+}
+
+// ListVolumeTypes fetches the list of volume types available to the project.
+func ListVolumeTypes(storageURL, token string) (VolumeTypeListResponse, error) {
+	var result VolumeTypeListResponse
+
+	url := fmt.Sprintf("%s/types", storageURL)
+
+	apiResp, err := callGET(url, token)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch volume types: %v", err)
+	}
+	if apiResp.ResponseCode != 200 {
+		return result, fmt.Errorf("list volume types request failed [%d]: %s", apiResp.ResponseCode, apiResp.Response)
+	}
+
+	err = json.Unmarshal([]byte(apiResp.Response), &result)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse volume types response: %v", err)
+	}
+	return result, nil
+}
@@ -84,6 +84,8 @@ For admin access, use "default" and "admin" respectively.`,
 			os.Exit(2)
 		}
 
+		// a token about to expire is replaced rather than reused
+		api.RenewWithin = tokenWarnWindow()
 		_, err := getAuthToken(host, domain, project, username, password)
 		if err != nil {
 			fmt.Printf("ERROR: %v\n", err)
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Defaults of the token_warn_minutes and password_warn_days config keys
+const (
+	defaultTokenWarnMinutes = 15
+	defaultPasswordWarnDays = 7
+)
+
+// authStatus is the JSON output of 'auth status'
+type authStatus struct {
+	Host              string            `json:"host"`
+	User              string            `json:"user,omitempty"`
+	UserID            string            `json:"user_id,omitempty"`
+	UserDomain        string            `json:"user_domain,omitempty"`
+	Project           string            `json:"project,omitempty"`
+	ProjectID         string            `json:"project_id,omitempty"`
+	Roles             []string          `json:"roles,omitempty"`
+	ExpiresAt         time.Time         `json:"expires_at"`
+	ExpiresIn         string            `json:"expires_in"`
+	Expired           bool              `json:"expired"`
+	PasswordExpiresAt *time.Time        `json:"password_expires_at,omitempty"`
+	Endpoints         map[string]string `json:"endpoints,omitempty"`
+}
+
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the saved token: user, project, roles, expiry and endpoints",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if tok.Value == "" {
+			return fmt.Errorf("no token saved for host '%s'; run 'vhicmd auth' first", tok.Host)
+		}
+
+		status := authStatus{
+			Host:              tok.Host,
+			User:              tok.UserName,
+			UserID:            tok.UserID,
+			UserDomain:        tok.UserDomain,
+			Project:           tok.Project,
+			ProjectID:         tok.ProjectID,
+			Roles:             tok.Roles,
+			ExpiresAt:         tok.ExpiresAt,
+			ExpiresIn:         expiryPhrase(tok.ExpiresAt),
+			Expired:           time.Now().After(tok.ExpiresAt),
+			PasswordExpiresAt: tok.PasswordExpiresAt,
+			Endpoints:         tok.Endpoints,
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(status, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+
+		fmt.Printf("Host:              %s\n", status.Host)
+		if status.UserID == "" {
+			fmt.Printf("User:              unknown; the token predates this version, run 'vhicmd auth' to renew it\n")
+		} else {
+			fmt.Printf("User:              %s (domain %s, ID %s)\n", status.User, status.UserDomain, status.UserID)
+		}
+		if status.ProjectID == "" {
+			fmt.Printf("Project:           %s\n", firstNonEmpty(status.Project, "-"))
+		} else {
+			fmt.Printf("Project:           %s (ID %s)\n", status.Project, status.ProjectID)
+		}
+		fmt.Printf("Roles:             %s\n", firstNonEmpty(strings.Join(status.Roles, ", "), "-"))
+		fmt.Printf("Token expires:     %s (%s)\n", status.ExpiresAt.Local().Format("2006-01-02 15:04 MST"), status.ExpiresIn)
+		if status.PasswordExpiresAt == nil {
+			fmt.Printf("Password expires:  never\n")
+		} else {
+			fmt.Printf("Password expires:  %s (%s)\n", status.PasswordExpiresAt.Local().Format("2006-01-02 15:04 MST"), expiryPhrase(*status.PasswordExpiresAt))
+		}
+
+		fmt.Printf("Endpoints:\n")
+		var types []string
+		for t := range status.Endpoints {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			fmt.Printf("  %-16s %s\n", t, status.Endpoints[t])
+		}
+		return nil
+	},
+}
+
+var authChangePasswordCmd = &cobra.Command{
+	Use:   "change-password",
+	Short: "Change your own password",
+	Long: `Change the password of the user of the saved token with Keystone's user
+password API. It needs the current password but no valid token, so an
+expired password can be changed too. Keystone revokes the user's tokens,
+so the saved token is dropped; a password saved in the config is updated.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		userID := firstNonEmpty(flagAuthUserID, tok.UserID)
+		if userID == "" {
+			return fmt.Errorf("no user ID in the saved token; run 'vhicmd auth' first or give --user-id")
+		}
+		identityURL := tok.Endpoints["identity"]
+		if identityURL == "" {
+			identityURL = fmt.Sprintf("https://%s:5000/v3", tok.Host)
+		}
+
+		current := flagAuthCurrentPassword
+		if current == "" {
+			current = viper.GetString("password")
+		}
+		if current == "" {
+			fmt.Print("current ")
+			var err error
+			if current, err = readPasswordFromStdin(); err != nil {
+				return err
+			}
+		}
+		password := flagAuthNewPassword
+		if password == "" {
+			var err error
+			if password, err = readNewPassword(); err != nil {
+				return err
+			}
+		}
+
+		if err := api.ChangePassword(identityURL, userID, current, password); err != nil {
+			return err
+		}
+		fmt.Printf("Password changed\n")
+
+		if err := api.ForgetToken(tok.Host); err != nil {
+			return err
+		}
+		if viper.InConfig("password") && viper.GetString("password") == current {
+			viper.Set("password", password)
+			if err := viper.WriteConfig(); err != nil {
+				return fmt.Errorf("failed to update the password in config: %v", err)
+			}
+			fmt.Printf("Password in config updated\n")
+		}
+		fmt.Printf("Tokens were revoked; run 'vhicmd auth' for a new one\n")
+		return nil
+	},
+}
+
+// warnExpiry() warns on stderr when the token expires within
+// token_warn_minutes or the password within password_warn_days
+func warnExpiry(t api.Token) {
+	if window := tokenWarnWindow(); window > 0 && time.Until(t.ExpiresAt) < window {
+		fmt.Fprintf(os.Stderr, "Warning: the auth token for %s expires %s; run 'vhicmd auth' to renew it\n", t.Host, expiryPhrase(t.ExpiresAt))
+	}
+
+	days := defaultPasswordWarnDays
+	if viper.IsSet("password_warn_days") {
+		days = viper.GetInt("password_warn_days")
+	}
+	if t.PasswordExpiresAt != nil && days > 0 && time.Until(*t.PasswordExpiresAt) < time.Duration(days)*24*time.Hour {
+		fmt.Fprintf(os.Stderr, "Warning: the password of %s expires %s; change it with 'vhicmd auth change-password'\n", firstNonEmpty(t.UserName, "your user"), expiryPhrase(*t.PasswordExpiresAt))
+	}
+}
+
+// tokenWarnWindow() is how long before expiry a token is warned about
+// and renewed by 'vhicmd auth'
+func tokenWarnWindow() time.Duration {
+	minutes := defaultTokenWarnMinutes
+	if viper.IsSet("token_warn_minutes") {
+		minutes = viper.GetInt("token_warn_minutes")
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// expiryPhrase() describes when at is, e.g. "in 2h05m" or "5m ago"
+func expiryPhrase(at time.Time) string {
+	d := time.Until(at)
+	if d < 0 {
+		return formatCountdown(-d) + " ago"
+	}
+	return "in " + formatCountdown(d)
+}
+
+// formatCountdown() renders d to the minute, e.g. "2d3h", "2h05m" or "4m"
+func formatCountdown(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := d / (24 * time.Hour)
+	hours := d % (24 * time.Hour) / time.Hour
+	minutes := d % time.Hour / time.Minute
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%02dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+var (
+	flagAuthUserID          string
+	flagAuthCurrentPassword string
+	flagAuthNewPassword     string
+)
+
+func init() {
+	authStatusCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+
+	authChangePasswordCmd.Flags().StringVar(&flagAuthUserID, "user-id", "", "User ID (default: the user of the saved token)")
+	authChangePasswordCmd.Flags().StringVar(&flagAuthCurrentPassword, "current-password", "", "Current password (default: from config, else prompted for)")
+	authChangePasswordCmd.Flags().StringVar(&flagAuthNewPassword, "new-password", "", "New password (prompted for if not given)")
+
+	authCmd.AddCommand(authStatusCmd)
+	authCmd.AddCommand(authChangePasswordCmd)
+}
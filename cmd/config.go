@@ -18,6 +18,15 @@ var configKeys = []string{
 	"networks",
 	"flavor_id",
 	"image_id",
+	"volume_type",
+	"disk_bus",
+	"vmdk_roots",
+	"vcenter_host",
+	"vcenter_username",
+	"vcenter_password",
+	"vcenter_insecure",
+	"token_warn_minutes",
+	"password_warn_days",
 }
 
 var configCmd = &cobra.Command{
@@ -41,7 +50,7 @@ var configListCmd = &cobra.Command{
 			value := settings[key]
 			if value == nil || value == "" {
 				fmt.Printf("%s: UNSET\n", key)
-			} else if key == "password" && value != "" {
+			} else if (key == "password" || key == "vcenter_password") && value != "" {
 				fmt.Printf("%s: ********\n", key)
 			} else {
 				fmt.Printf("%s: %v\n", key, value)
@@ -3,12 +3,15 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/diskimage"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -22,43 +25,78 @@ var createCmd = &cobra.Command{
 var createImageCmd = &cobra.Command{
 	Use:   "image",
 	Short: "Create a new image",
-	Long:  "Create a new image from a VM snapshot (.qcow2, .raw, .vmdk, .iso)",
+	Long: `Create a new image from a disk file (qcow2, raw, vmdk, vhd, vhdx, vdi, iso).
+The format is detected from the file content; --format is only a cross-check.
+
+Use --file - to stream from stdin (--format required), e.g.
+  qemu-img convert -O raw disk.vmdk /dev/stdout | vhicmd create image --file - --format raw --name disk
+or --url to have Glance fetch the image itself (web-download import).`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		imageURL, err := validateTokenEndpoint(tok, "image")
 		if err != nil {
 			return err
 		}
 
+		if flagImageURL != "" {
+			return createImageFromURL(imageURL)
+		}
+		if flagImageFile == "-" {
+			if cmd.Flags().Changed("retries") && flagImageRetries != 0 {
+				return fmt.Errorf("--retries can't be used with --file -; stdin can't be replayed for a retry")
+			}
+			return createImageFromStdin(imageURL)
+		}
+
 		// Validate file exists
 		if _, err := os.Stat(flagImageFile); os.IsNotExist(err) {
 			return fmt.Errorf("image file not found: %s", flagImageFile)
 		}
 
-		// Determine format from flag or file extension if not specified
-		format := flagDiskFormat
-		if format == "" {
-			ext := strings.ToLower(filepath.Ext(flagImageFile))
-			switch ext {
-			case ".qcow2":
-				format = "qcow2"
-			case ".raw":
-				format = "raw"
-			case ".vmdk":
-				format = "vmdk"
-			case ".iso":
-				format = "iso"
-			default:
-				return fmt.Errorf("unsupported image format %s, must specify --format flag", ext)
-			}
+		// Detect the real format from the file content, so mislabeled
+		// files fail here instead of after a multi-GB upload
+		detected, err := diskimage.DetectFile(flagImageFile)
+		if err != nil {
+			return fmt.Errorf("failed to detect image format: %v", err)
+		}
+
+		// The format the user claims, from flag or file extension
+		claimed := flagDiskFormat
+		if claimed == "" {
+			claimed = formatFromExtension(flagImageFile)
 		}
 
-		switch format {
-		case "qcow2", "raw", "vmdk", "iso":
-			// Valid formats
+		format := detected.Format
+		switch {
+		case claimed == "" || claimed == detected.Format:
+		case claimed == "vmdk" && detected.Format == "raw" && strings.HasSuffix(flagImageFile, "-flat.vmdk"):
+			// flat VMDK extents carry no header, they're raw disk data
+		case claimed == "raw" && detected.Format == "iso":
+			// an ISO is valid raw data as well
+			format = "raw"
 		default:
-			return fmt.Errorf("unsupported format %s, must be qcow2, raw, vmdk or iso", format)
+			return fmt.Errorf("%s looks like %s, not %s; fix the file or pass --format %s", flagImageFile, detected.Format, claimed, detected.Format)
 		}
 
+		if err := validateImageFormat(format); err != nil {
+			return err
+		}
+
+		// min_disk must fit the virtual disk, default to the rounded-up size
+		virtualGB := int((detected.VirtualSize + 1024*1024*1024 - 1) / (1024 * 1024 * 1024))
+		minDisk := flagImageMinDisk
+		if minDisk == 0 && format != "iso" {
+			minDisk = virtualGB
+		}
+		if minDisk > 0 && minDisk < virtualGB {
+			return fmt.Errorf("--min-disk %d GB is smaller than the image's virtual size (%d GB)", minDisk, virtualGB)
+		}
+
+		fmt.Printf("Detected format: %s", format)
+		if detected.Detail != "" {
+			fmt.Printf(" (%s)", detected.Detail)
+		}
+		fmt.Printf(", virtual size: %d GB\n", virtualGB)
+
 		file, err := os.Open(flagImageFile)
 		if err != nil {
 			return fmt.Errorf("failed to open image file: %v", err)
@@ -78,14 +116,17 @@ var createImageCmd = &cobra.Command{
 
 		fmt.Printf("Starting upload of %s (%d MB)\n", flagImageFile, info.Size()/1024/1024)
 
-		req := api.CreateImageRequest{
-			Name:         name,
-			ContainerFmt: "bare",
-			DiskFmt:      format,
-			Visibility:   "shared",
+		req, err := imageRequestFromFlags(name, format, minDisk)
+		if err != nil {
+			return err
+		}
+
+		opts, err := uploadOptionsFromFlags(flagImageBwLimit, flagImageRetries, flagImageStage)
+		if err != nil {
+			return err
 		}
 
-		imageID, err := api.CreateAndUploadImage(imageURL, tok.Value, req, file)
+		imageID, err := api.CreateAndUploadImageWithOptions(imageURL, tok.Value, req, file, opts)
 		if err != nil {
 			return fmt.Errorf("failed to create/upload image: %v", err)
 		}
@@ -95,6 +136,137 @@ var createImageCmd = &cobra.Command{
 	},
 }
 
+// createImageFromStdin() streams image data of unknown length from stdin,
+// e.g. the output of qemu-img convert or zstdcat. The data can't be
+// inspected or replayed, so --format is required and retries are off.
+func createImageFromStdin(imageURL string) error {
+	if flagDiskFormat == "" {
+		return fmt.Errorf("--format is required when reading from stdin")
+	}
+	if err := validateImageFormat(flagDiskFormat); err != nil {
+		return err
+	}
+
+	name := flagImageName
+	if name == "" {
+		name = fmt.Sprintf("stdin-%s", time.Now().Format("20060102-150405"))
+	}
+
+	req, err := imageRequestFromFlags(name, flagDiskFormat, flagImageMinDisk)
+	if err != nil {
+		return err
+	}
+
+	opts, err := uploadOptionsFromFlags(flagImageBwLimit, 0, flagImageStage)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Starting upload from stdin (%s, length unknown)\n", flagDiskFormat)
+
+	imageID, err := api.CreateAndUploadImageWithOptions(imageURL, tok.Value, req, os.Stdin, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create/upload image: %v", err)
+	}
+
+	fmt.Printf("Image created: ID: %s, Name: %s\n", imageID, name)
+	return nil
+}
+
+// createImageFromURL() has Glance download the image itself using the
+// web-download import method, so the data never passes through this host.
+func createImageFromURL(imageURL string) error {
+	u, err := url.Parse(flagImageURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("invalid --url %q; must be an http or https URL", flagImageURL)
+	}
+
+	format := flagDiskFormat
+	if format == "" {
+		format = formatFromExtension(u.Path)
+	}
+	if format == "" {
+		return fmt.Errorf("cannot tell the disk format from %s; pass --format", flagImageURL)
+	}
+	if err := validateImageFormat(format); err != nil {
+		return err
+	}
+
+	name := flagImageName
+	if name == "" {
+		name = fmt.Sprintf("%s-%s", path.Base(u.Path), time.Now().Format("20060102-150405"))
+	}
+
+	req, err := imageRequestFromFlags(name, format, flagImageMinDisk)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Importing %s into Glance via web-download...\n", flagImageURL)
+
+	imageID, err := api.CreateImageFromURL(imageURL, tok.Value, req, flagImageURL)
+	if err != nil {
+		return fmt.Errorf("failed to import image: %v", err)
+	}
+
+	fmt.Printf("Image created: ID: %s, Name: %s\n", imageID, name)
+	return nil
+}
+
+// imageRequestFromFlags() builds the image create request shared by all
+// create image sources
+func imageRequestFromFlags(name, format string, minDisk int) (api.CreateImageRequest, error) {
+	props, err := parseKeyValues(flagImageProperties)
+	if err != nil {
+		return api.CreateImageRequest{}, err
+	}
+
+	req := api.CreateImageRequest{
+		Name:         name,
+		ContainerFmt: "bare",
+		DiskFmt:      format,
+		MinDisk:      minDisk,
+		Visibility:   flagImageVisibility,
+		Properties:   props,
+	}
+	// catch e.g. a non-numeric min_disk before anything is uploaded
+	if _, err := req.MarshalJSON(); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// formatFromExtension() guesses the Glance disk format from a file name,
+// returning "" when the extension isn't recognised
+func formatFromExtension(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".qcow2", ".qcow":
+		return "qcow2"
+	case ".raw", ".img":
+		return "raw"
+	case ".vmdk":
+		return "vmdk"
+	case ".vhd":
+		return "vhd"
+	case ".vhdx":
+		return "vhdx"
+	case ".vdi":
+		return "vdi"
+	case ".iso":
+		return "iso"
+	}
+	return ""
+}
+
+// validateImageFormat() checks format is one Glance accepts from us
+func validateImageFormat(format string) error {
+	switch format {
+	case "qcow2", "raw", "vmdk", "vhd", "vhdx", "vdi", "iso":
+		return nil
+	}
+	return fmt.Errorf("unsupported format %s, must be qcow2, raw, vmdk, vhd, vhdx, vdi or iso", format)
+}
+
 // Subcommand: create volume
 var createVolumeCmd = &cobra.Command{
 	Use:   "volume",
@@ -110,6 +282,9 @@ var createVolumeCmd = &cobra.Command{
 		request.Volume.Size = flagVolumeSize
 		request.Volume.Description = flagVolumeDescription
 		request.Volume.VolumeType = flagVolumeType
+		if request.Volume.VolumeType == "" {
+			request.Volume.VolumeType = viper.GetString("volume_type")
+		}
 
 		resp, err := api.CreateVolume(storageURL, tok.Value, request)
 		if err != nil {
@@ -173,8 +348,15 @@ var (
 	flagVolumeDescription string
 	flagVolumeType        string
 	flagImageFile         string
+	flagImageURL          string
 	flagImageName         string
 	flagDiskFormat        string
+	flagImageVisibility   string
+	flagImageProperties   []string
+	flagImageMinDisk      int
+	flagImageBwLimit      string
+	flagImageRetries      int
+	flagImageStage        bool
 	flagPortNetwork       string
 	flagPortMAC           string
 )
@@ -191,6 +373,14 @@ func init() {
 	createVMCmd.Flags().BoolVar(&flagVMNetboot, "netboot", false, "Enable network boot with blank volume (deprecated, use --image)")
 	createVMCmd.Flags().StringVar(&flagUserData, "user-data", "", "User data for cloud-init (file path)")
 	createVMCmd.Flags().StringVar(&flagMacAddrCSV, "macaddr", "", "Comma-separated list of MAC addresses")
+	createVMCmd.Flags().StringVar(&flagVMVolumeType, "volume-type", "", "Volume type for the boot volume (default: 'volume_type' from config)")
+	createVMCmd.Flags().StringVar(&flagVMDiskBus, "disk-bus", "", "Disk bus for the boot volume: sata, scsi, virtio, ide (default: 'disk_bus' from config, then image hw_disk_bus)")
+	createVMCmd.Flags().StringArrayVar(&flagVMDataVolumes, "volume", nil, "Extra data volume, repeatable: size=<GB>[,type=<type>][,bus=<bus>][,delete=true|false]")
+	createVMCmd.Flags().StringVar(&flagVMBootVolume, "boot-volume", "", "Boot from an existing volume (name or ID)")
+	createVMCmd.Flags().StringVar(&flagVMBootSnapshot, "boot-snapshot", "", "Boot from a new volume created from a volume snapshot (name or ID)")
+	createVMCmd.Flags().BoolVar(&flagVMEphemeral, "ephemeral", false, "Boot the image from local compute-node disk instead of a volume")
+	createVMCmd.Flags().StringVar(&flagVMISO, "iso", "", "Install from an ISO image: attach it as a cdrom and boot it ahead of a blank boot volume")
+	addFlavorMatchFlags(createVMCmd, &flagVMCPUs, &flagVMRAM, &flagVMExact, &flagVMPrefer)
 
 	// Bind flags to viper
 	viper.BindPFlag("flavor_id", createVMCmd.Flags().Lookup("flavor"))
@@ -198,20 +388,33 @@ func init() {
 	viper.BindPFlag("networks", createVMCmd.Flags().Lookup("networks"))
 
 	createVMCmd.MarkFlagRequired("name")
+	createVMCmd.MarkFlagsMutuallyExclusive("boot-volume", "boot-snapshot", "ephemeral", "netboot", "iso")
+	createVMCmd.MarkFlagsMutuallyExclusive("flavor", "cpus")
+	createVMCmd.MarkFlagsMutuallyExclusive("flavor", "ram")
 
 	// Flags for create volume
 	createVolumeCmd.Flags().StringVar(&flagVolumeName, "name", "", "Name of the volume")
 	createVolumeCmd.Flags().IntVar(&flagVolumeSize, "size", 0, "Size of the volume in GB")
 	createVolumeCmd.Flags().StringVar(&flagVolumeDescription, "description", "", "Description of the volume")
-	createVolumeCmd.Flags().StringVar(&flagVolumeType, "type", "nvme_ec7_2", "Type of the volume: nvme_ec7_2, replica3")
+	createVolumeCmd.Flags().StringVar(&flagVolumeType, "type", "", "Type of the volume (default: 'volume_type' from config, see 'list volume-types')")
 
 	createVolumeCmd.MarkFlagRequired("name")
 	createVolumeCmd.MarkFlagRequired("size")
 
 	// Flags for create image
-	createImageCmd.Flags().StringVar(&flagImageFile, "file", "", "Path to the image file")
+	createImageCmd.Flags().StringVar(&flagImageFile, "file", "", "Path to the image file, or - to read from stdin")
+	createImageCmd.Flags().StringVar(&flagImageURL, "url", "", "http(s) URL for Glance to download the image from (web-download import)")
 	createImageCmd.Flags().StringVar(&flagImageName, "name", "", "Name of the image")
-	createImageCmd.Flags().StringVar(&flagDiskFormat, "format", "", "Disk format (qcow2, raw, vmdk)")
+	createImageCmd.Flags().StringVar(&flagDiskFormat, "format", "", "Expected disk format (qcow2, raw, vmdk, vhd, vhdx, vdi, iso); checked against the detected format")
+	createImageCmd.Flags().IntVar(&flagImageMinDisk, "min-disk", 0, "Minimum disk size in GB (default: the image's virtual size)")
+	createImageCmd.Flags().StringVar(&flagImageBwLimit, "bwlimit", "", "Limit upload bandwidth in bytes/s, e.g. 50M (default: unlimited)")
+	createImageCmd.Flags().IntVar(&flagImageRetries, "retries", 3, "Retry a failed upload this many times; each retry resends the file from the start")
+	createImageCmd.Flags().BoolVar(&flagImageStage, "stage", false, "Upload via Glance stage + glance-direct import instead of a direct PUT")
+	createImageCmd.Flags().StringVar(&flagImageVisibility, "visibility", "shared", "Image visibility: private, shared, community, public")
+	createImageCmd.Flags().StringArrayVar(&flagImageProperties, "property", nil, "Image property key=value, repeatable (e.g. hw_disk_bus=scsi)")
+
+	createImageCmd.MarkFlagsOneRequired("file", "url")
+	createImageCmd.MarkFlagsMutuallyExclusive("file", "url")
 
 	// Flags for create port
 	createPortCmd.Flags().StringVar(&flagPortNetwork, "network", "", "Network ID or name")
@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -44,11 +45,23 @@ var createVMCmd = &cobra.Command{
 
 		// Get required parameters
 		flavorRef := flagFlavorRef
+		if flavorRef == "" && (flagVMCPUs > 0 || flagVMRAM != "") {
+			req, err := flavorRequestFromFlags(flagVMCPUs, flagVMRAM, flagVMExact, flagVMPrefer)
+			if err != nil {
+				return err
+			}
+			flavor, err := closestFlavor(computeURL, req)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Using flavor %s (%d vCPU, %d MB RAM)\n", flavor.Name, flavor.VCPUs, flavor.RAM)
+			flavorRef = flavor.ID
+		}
 		if flavorRef == "" {
 			flavorRef = viper.GetString("flavor_id")
 		}
 		if flavorRef == "" {
-			return fmt.Errorf("no flavor specified; provide --flavor flag or set 'flavor_id' in config")
+			return fmt.Errorf("no flavor specified; provide --flavor or --cpus/--ram, or set 'flavor_id' in config")
 		}
 
 		// Ensure networks are specified
@@ -117,6 +130,11 @@ var createVMCmd = &cobra.Command{
 		//	})
 		//}
 
+		volumeType := flagVMVolumeType
+		if volumeType == "" {
+			volumeType = viper.GetString("volume_type")
+		}
+
 		// Calculate volume size
 		volumeSize := 10 // Default minimum
 		if flagVMSize > 0 {
@@ -142,31 +160,145 @@ var createVMCmd = &cobra.Command{
 			}
 		}
 
-		// Determine block device mapping
-		if imageRef != "" {
+		// An existing volume, snapshot or installer ISO replaces the image as boot source
+		if flagVMBootVolume != "" || flagVMBootSnapshot != "" || flagVMISO != "" {
+			if cmd.Flags().Changed("image") {
+				return fmt.Errorf("--image cannot be combined with --boot-volume, --boot-snapshot or --iso")
+			}
+			imageRef = ""
+		}
+		if flagVMEphemeral && imageRef == "" {
+			return fmt.Errorf("--ephemeral requires an image; provide --image or set 'image_id' in config")
+		}
+
+		// Parse extra data volumes up front so a typo fails before anything is created
+		var dataVolumes []map[string]interface{}
+		for _, spec := range flagVMDataVolumes {
+			mapping, err := parseVolumeSpec(spec, volumeType)
+			if err != nil {
+				return err
+			}
+			dataVolumes = append(dataVolumes, mapping)
+		}
+
+		// A snapshot's volume is at least as large as the snapshot
+		var snap api.Snapshot
+		bootSize := volumeSize
+		if flagVMBootSnapshot != "" {
+			if snap, err = api.GetSnapshotByNameOrID(storageURL, tok.Value, flagVMBootSnapshot); err != nil {
+				return err
+			}
+			if flagVMSize == 0 || bootSize < snap.Size {
+				bootSize = snap.Size
+			}
+		}
+
+		// Fail on quota before anything is created; the ISO's own volume
+		// is counted without its size, which isn't known yet
+		newVolumes, newGB := 0, int64(0)
+		if flagVMBootVolume == "" && !flagVMEphemeral {
+			newVolumes, newGB = 1, int64(bootSize)
+		}
+		if flagVMISO != "" {
+			newVolumes++
+		}
+		for _, mapping := range dataVolumes {
+			newVolumes++
+			if size, ok := mapping["volume_size"].(int); ok {
+				newGB += int64(size)
+			}
+		}
+		if err := checkVMQuota(flavorRef, newVolumes, newGB, volumeType, len(networkIDs)); err != nil {
+			return err
+		}
+
+		// Disk bus falls back to the image's hw_disk_bus property
+		diskBus, err := resolveDiskBus(flagVMDiskBus, imageURL, imageRef)
+		if err != nil {
+			return err
+		}
+
+		// Determine block device mapping for the boot disk
+		var bootMapping, isoMapping map[string]interface{}
+		switch {
+		case flagVMISO != "":
+			// install from ISO: the ISO is attached as a cdrom and boots first,
+			// the blank volume is the install target and boots once the ISO is gone
+			isoID := flagVMISO
+			if id, err := api.GetImageIDByName(imageURL, tok.Value, isoID); err == nil {
+				isoID = id
+			}
+			isoSize, err := api.GetImageSize(imageURL, tok.Value, isoID)
+			if err != nil {
+				return fmt.Errorf("failed to get ISO image size: %v", err)
+			}
+			isoMapping = map[string]interface{}{
+				"boot_index":            "0",
+				"uuid":                  isoID,
+				"source_type":           "image",
+				"destination_type":      "volume",
+				"device_type":           "cdrom",
+				"disk_bus":              "sata",
+				"volume_size":           (isoSize + 1024*1024*1024 - 1) / (1024 * 1024 * 1024),
+				"delete_on_termination": true,
+			}
+			bootMapping = map[string]interface{}{
+				"boot_index":            "1",
+				"source_type":           "blank",
+				"destination_type":      "volume",
+				"volume_size":           volumeSize,
+				"delete_on_termination": true,
+			}
+			if volumeType != "" {
+				bootMapping["volume_type"] = volumeType
+			}
+		case flagVMBootVolume != "":
+			// boot from an existing volume, which outlives the VM
+			volumeID := resolveVolumeID(storageURL, flagVMBootVolume)
+			bootMapping = map[string]interface{}{
+				"boot_index":            "0",
+				"uuid":                  volumeID,
+				"source_type":           "volume",
+				"destination_type":      "volume",
+				"delete_on_termination": false,
+			}
+		case flagVMBootSnapshot != "":
+			// boot from a new volume created from a volume snapshot
+			bootMapping = map[string]interface{}{
+				"boot_index":            "0",
+				"uuid":                  snap.ID,
+				"source_type":           "snapshot",
+				"destination_type":      "volume",
+				"volume_size":           bootSize,
+				"delete_on_termination": true,
+			}
+			if volumeType != "" {
+				bootMapping["volume_type"] = volumeType
+			}
+		case imageRef != "" && flagVMEphemeral:
+			// boot from the image on the compute node's local disk
+			request.Server.ImageRef = imageRef
+			bootMapping = map[string]interface{}{
+				"boot_index":            "0",
+				"uuid":                  imageRef,
+				"source_type":           "image",
+				"destination_type":      "local",
+				"delete_on_termination": true,
+			}
+		case imageRef != "":
 			// imageRef exists, create boot volume from image
-			request.Server.BlockDeviceMappingV2 = []map[string]interface{}{
-				{
-					"boot_index":            "0",
-					"uuid":                  imageRef,
-					"source_type":           "image",
-					"destination_type":      "volume",
-					"volume_size":           volumeSize,
-					"delete_on_termination": true,
-					"volume_type":           "nvme_ec7_2",
-					"disk_bus":              "scsi",
-				},
-			}
-			// cloud-init script
-			// VHI calls this user_data, just b64 encoded cloud-init script
-			if flagUserData != "" {
-				userData, err := readAndEncodeUserData(flagUserData)
-				if err != nil {
-					return err
-				}
-				request.Server.UserData = userData
+			bootMapping = map[string]interface{}{
+				"boot_index":            "0",
+				"uuid":                  imageRef,
+				"source_type":           "image",
+				"destination_type":      "volume",
+				"volume_size":           volumeSize,
+				"delete_on_termination": true,
 			}
-		} else {
+			if volumeType != "" {
+				bootMapping["volume_type"] = volumeType
+			}
+		default:
 			// Create blank volume if no image is specified
 			// we get here if --netboot is flagged or --image is not provided
 			// and there is no image in the Vyper config
@@ -175,7 +307,7 @@ var createVMCmd = &cobra.Command{
 			volRequest.Volume.Name = fmt.Sprintf("%s-boot", flagVMName)
 			volRequest.Volume.Size = volumeSize
 			volRequest.Volume.Description = "Boot volume for " + flagVMName
-			volRequest.Volume.VolumeType = "nvme_ec7_2"
+			volRequest.Volume.VolumeType = volumeType
 
 			volResp, err := api.CreateVolume(storageURL, tok.Value, volRequest)
 			if err != nil {
@@ -194,15 +326,31 @@ var createVMCmd = &cobra.Command{
 				return fmt.Errorf("failed to set bootable flag: %v", err)
 			}
 
-			request.Server.BlockDeviceMappingV2 = []map[string]interface{}{
-				{
-					"boot_index":            "0",
-					"uuid":                  volResp.Volume.ID,
-					"source_type":           "volume",
-					"destination_type":      "volume",
-					"delete_on_termination": true,
-				},
+			bootMapping = map[string]interface{}{
+				"boot_index":            "0",
+				"uuid":                  volResp.Volume.ID,
+				"source_type":           "volume",
+				"destination_type":      "volume",
+				"delete_on_termination": true,
+			}
+		}
+		if diskBus != "" && bootMapping["destination_type"] == "volume" {
+			bootMapping["disk_bus"] = diskBus
+		}
+		request.Server.BlockDeviceMappingV2 = append([]map[string]interface{}{bootMapping}, dataVolumes...)
+		if isoMapping != nil {
+			request.Server.BlockDeviceMappingV2 = append([]map[string]interface{}{isoMapping}, request.Server.BlockDeviceMappingV2...)
+		}
+
+		// cloud-init script
+		// VHI calls this user_data, just b64 encoded cloud-init script
+		// (skipped for blank volumes, there is nothing to run it)
+		if flagUserData != "" && (imageRef != "" || flagVMBootVolume != "" || flagVMBootSnapshot != "") {
+			userData, err := readAndEncodeUserData(flagUserData)
+			if err != nil {
+				return err
 			}
+			request.Server.UserData = userData
 		}
 
 		// Create the VM
@@ -312,8 +460,8 @@ var createVMCmd = &cobra.Command{
 			fmt.Println(string(yamlBytes))
 		}
 
-		// Print netboot command if enabled
-		if flagVMNetboot {
+		// Print console URL if an installer has to be run
+		if flagVMNetboot || flagVMISO != "" {
 			consoleURL := fmt.Sprintf("%s:8800/compute/servers/instances/%s/console", tok.Host, vmDetails.ID)
 			fmt.Printf("\nGo to VHI console to complete machine bootup and installation.")
 			fmt.Printf("\nVHI console: %s\n", consoleURL)
@@ -333,4 +481,66 @@ var (
 	flagVMNetboot  bool
 	flagUserData   string
 	flagMacAddrCSV string
+
+	flagVMVolumeType   string
+	flagVMDiskBus      string
+	flagVMDataVolumes  []string
+	flagVMBootVolume   string
+	flagVMBootSnapshot string
+	flagVMEphemeral    bool
+	flagVMISO          string
+
+	flagVMCPUs   int
+	flagVMRAM    string
+	flagVMExact  bool
+	flagVMPrefer string
 )
+
+// parseVolumeSpec() turns a --volume value like "size=100,type=replica3,bus=virtio"
+// into a block device mapping for a new blank data volume
+func parseVolumeSpec(spec, defaultType string) (map[string]interface{}, error) {
+	mapping := map[string]interface{}{
+		"boot_index":            -1,
+		"source_type":           "blank",
+		"destination_type":      "volume",
+		"delete_on_termination": true,
+	}
+	if defaultType != "" {
+		mapping["volume_type"] = defaultType
+	}
+
+	for _, kv := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid volume option %q in %q; expected key=value", kv, spec)
+		}
+		switch strings.ToLower(key) {
+		case "size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return nil, fmt.Errorf("invalid volume size %q in %q", value, spec)
+			}
+			mapping["volume_size"] = size
+		case "type":
+			mapping["volume_type"] = value
+		case "bus":
+			if err := validateDiskBus(value); err != nil {
+				return nil, err
+			}
+			mapping["disk_bus"] = value
+		case "delete":
+			del, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid delete value %q in %q; must be true or false", value, spec)
+			}
+			mapping["delete_on_termination"] = del
+		default:
+			return nil, fmt.Errorf("unknown volume option %q in %q; valid options: size, type, bus, delete", key, spec)
+		}
+	}
+
+	if _, ok := mapping["volume_size"]; !ok {
+		return nil, fmt.Errorf("volume %q is missing size=<GB>", spec)
+	}
+	return mapping, nil
+}
@@ -0,0 +1,501 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/facette/natsort"
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/responseparser"
+	"github.com/spf13/cobra"
+)
+
+var flavorCmd = &cobra.Command{
+	Use:   "flavor",
+	Short: "Manage flavors",
+}
+
+var flavorMatchCmd = &cobra.Command{
+	Use:   "match",
+	Short: "Show the flavor --cpus and --ram would pick",
+	Long: `Preview the flavor 'create vm' and 'migrate vm' pick for --cpus, --ram
+and --disk: the smallest enabled public flavor with at least that much, or
+exactly that much with --exact. All matching flavors are listed, the picked one
+first; --prefer decides between flavors that are larger in different ways.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		req, err := flavorRequestFromFlags(flagFlavorCPUs, flagFlavorRAM, flagFlavorExact, flagFlavorPrefer)
+		if err != nil {
+			return err
+		}
+		req.DiskGB = flagFlavorDisk
+
+		flavors, err := matchFlavors(computeURL, req)
+		if err != nil {
+			return err
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(flavors, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+
+		var rows []responseparser.FlavorMatch
+		for i, f := range flavors {
+			rows = append(rows, responseparser.FlavorMatch{
+				Name:   f.Name,
+				ID:     f.ID,
+				VCPUs:  f.VCPUs,
+				RAM:    f.RAM,
+				Disk:   f.Disk,
+				Picked: i == 0,
+			})
+		}
+		responseparser.PrintFlavorMatchTable(rows)
+		return nil
+	},
+}
+
+var flavorCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a flavor",
+	Long: `Create a flavor, optionally with extra specs (CPU pinning, NUMA, disk QoS)
+and as a private flavor for some projects only.
+
+Examples:
+  vhicmd flavor create pinned.4x16 --vcpus 4 --ram 16G --spec hw:cpu_policy=dedicated --spec hw:numa_nodes=1
+  vhicmd flavor create cust.8x32 --vcpus 8 --ram 32G --private --project customer1`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		specs, err := parseKeyValues(flagFlavorSpecs)
+		if err != nil {
+			return err
+		}
+		ramMB, err := parseRAMMB(flagFlavorRAM)
+		if err != nil {
+			return err
+		}
+		if flagFlavorCPUs < 1 || ramMB < 1 {
+			return fmt.Errorf("--vcpus and --ram must be positive")
+		}
+		if len(flagFlavorProjects) > 0 && !flagFlavorPrivate {
+			return fmt.Errorf("--project only applies to --private flavors")
+		}
+		var projects []api.ProjectDetail
+		for _, nameOrID := range flagFlavorProjects {
+			project, err := findFlavorProject(nameOrID)
+			if err != nil {
+				return err
+			}
+			projects = append(projects, project)
+		}
+
+		var request api.CreateFlavorRequest
+		request.Flavor.Name = args[0]
+		request.Flavor.ID = flagFlavorID
+		request.Flavor.VCPUs = flagFlavorCPUs
+		request.Flavor.RAM = ramMB
+		request.Flavor.Disk = flagFlavorDisk
+		request.Flavor.Ephemeral = flagFlavorEphemeral
+		request.Flavor.Swap = flagFlavorSwap
+		request.Flavor.IsPublic = !flagFlavorPrivate
+		request.Flavor.Description = flagFlavorDescription
+
+		flavor, err := api.CreateFlavor(computeURL, tok.Value, request)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Flavor %s created: %s (%d vCPU, %d MB RAM, %d GB disk)\n", flavor.Name, flavor.ID, flavor.VCPUs, flavor.RAM, flavor.Disk)
+
+		if len(specs) > 0 {
+			if err := api.SetFlavorExtraSpecs(computeURL, tok.Value, flavor.ID, specs); err != nil {
+				return err
+			}
+			fmt.Printf("Set %d extra spec(s)\n", len(specs))
+		}
+		for _, project := range projects {
+			if err := api.AddFlavorAccess(computeURL, tok.Value, flavor.ID, project.ID); err != nil {
+				return err
+			}
+			fmt.Printf("Project %s (%s) can use flavor %s\n", project.Name, project.ID, flavor.Name)
+		}
+		return nil
+	},
+}
+
+var flavorDeleteCmd = &cobra.Command{
+	Use:   "delete <flavor>",
+	Short: "Delete a flavor",
+	Long: `Delete a flavor. VMs already created with it keep running, but can't be
+resized back to it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		flavorID := resolveFlavorID(computeURL, args[0])
+		if err := api.DeleteFlavor(computeURL, tok.Value, flavorID); err != nil {
+			return err
+		}
+
+		fmt.Printf("Flavor %s deleted\n", flavorID)
+		return nil
+	},
+}
+
+var flavorSetSpecCmd = &cobra.Command{
+	Use:   "set-spec <flavor> <key=value>...",
+	Short: "Set extra specs of a flavor",
+	Long: `Add or replace extra specs of a flavor.
+
+Examples:
+  vhicmd flavor set-spec pinned.4x16 hw:cpu_policy=dedicated hw:cpu_thread_policy=prefer
+  vhicmd flavor set-spec db.8x32 hw:numa_nodes=2 quota:disk_total_iops_sec=5000`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		specs, err := parseKeyValues(args[1:])
+		if err != nil {
+			return err
+		}
+
+		flavorID := resolveFlavorID(computeURL, args[0])
+		if err := api.SetFlavorExtraSpecs(computeURL, tok.Value, flavorID, specs); err != nil {
+			return err
+		}
+
+		fmt.Printf("Extra specs of flavor %s updated\n", flavorID)
+		return nil
+	},
+}
+
+var flavorUnsetSpecCmd = &cobra.Command{
+	Use:   "unset-spec <flavor> <key>...",
+	Short: "Remove extra specs from a flavor",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		flavorID := resolveFlavorID(computeURL, args[0])
+		for _, key := range args[1:] {
+			if err := api.DeleteFlavorExtraSpec(computeURL, tok.Value, flavorID, key); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("Extra specs of flavor %s removed: %s\n", flavorID, strings.Join(args[1:], ", "))
+		return nil
+	},
+}
+
+var flavorAccessCmd = &cobra.Command{
+	Use:   "access",
+	Short: "Manage which projects can use a private flavor",
+}
+
+var flavorAccessListCmd = &cobra.Command{
+	Use:   "list <flavor>",
+	Short: "List the projects that can use a private flavor",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		flavorID := resolveFlavorID(computeURL, args[0])
+		access, err := api.ListFlavorAccess(computeURL, tok.Value, flavorID)
+		if err != nil {
+			return err
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(access, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+		for _, a := range access {
+			fmt.Println(a.ProjectID)
+		}
+		return nil
+	},
+}
+
+var flavorAccessAddCmd = &cobra.Command{
+	Use:   "add <flavor> <project>",
+	Short: "Let a project use a private flavor",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		flavorID := resolveFlavorID(computeURL, args[0])
+		project, err := findFlavorProject(args[1])
+		if err != nil {
+			return err
+		}
+		if err := api.AddFlavorAccess(computeURL, tok.Value, flavorID, project.ID); err != nil {
+			return err
+		}
+
+		fmt.Printf("Project %s (%s) can use flavor %s\n", project.Name, project.ID, flavorID)
+		return nil
+	},
+}
+
+var flavorAccessRemoveCmd = &cobra.Command{
+	Use:   "remove <flavor> <project>",
+	Short: "Revoke a project's access to a private flavor",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		flavorID := resolveFlavorID(computeURL, args[0])
+		project, err := findFlavorProject(args[1])
+		if err != nil {
+			return err
+		}
+		if err := api.RemoveFlavorAccess(computeURL, tok.Value, flavorID, project.ID); err != nil {
+			return err
+		}
+
+		fmt.Printf("Project %s (%s) can no longer use flavor %s\n", project.Name, project.ID, flavorID)
+		return nil
+	},
+}
+
+// findFlavorProject() returns the project nameOrID, looked up in
+// --project-domain if given
+func findFlavorProject(nameOrID string) (api.ProjectDetail, error) {
+	identityURL, err := validateTokenEndpoint(tok, "identity")
+	if err != nil {
+		return api.ProjectDetail{}, err
+	}
+	return findProject(identityURL, nameOrID, flagFlavorProjectDomain)
+}
+
+// resolveFlavorID returns the ID of the flavor named nameOrID, or
+// nameOrID unchanged if no single flavor has that name.
+func resolveFlavorID(computeURL, nameOrID string) string {
+	flavors, err := api.ListFlavors(computeURL, tok.Value, map[string]string{"is_public": "None"})
+	if err != nil {
+		return nameOrID
+	}
+	var ids []string
+	for _, f := range flavors.Flavors {
+		if f.Name == nameOrID {
+			ids = append(ids, f.ID)
+		}
+	}
+	if len(ids) == 1 {
+		return ids[0]
+	}
+	return nameOrID
+}
+
+// flavorRequest is the size of a VM to pick a flavor for
+type flavorRequest struct {
+	VCPUs  int
+	RAMMB  int
+	DiskGB int
+	Exact  bool   // only flavors of exactly VCPUs and RAMMB, where set
+	Prefer string // tie-break between larger flavors: cpu or ram
+}
+
+// String describes the request for messages
+func (r flavorRequest) String() string {
+	parts := []string{fmt.Sprintf("%d vCPU", r.VCPUs), fmt.Sprintf("%d MB RAM", r.RAMMB)}
+	if r.DiskGB > 0 {
+		parts = append(parts, fmt.Sprintf("%d GB disk", r.DiskGB))
+	}
+	if r.Exact {
+		return "exactly " + strings.Join(parts, ", ")
+	}
+	return "at least " + strings.Join(parts, ", ")
+}
+
+// flavorRequestFromFlags() builds a flavor request from the --cpus,
+// --ram, --exact and --prefer flag values
+func flavorRequestFromFlags(cpus int, ram string, exact bool, prefer string) (flavorRequest, error) {
+	req := flavorRequest{VCPUs: cpus, Exact: exact, Prefer: prefer}
+	if cpus < 0 {
+		return req, fmt.Errorf("--cpus must not be negative")
+	}
+
+	if ram != "" {
+		mb, err := parseRAMMB(ram)
+		if err != nil {
+			return req, err
+		}
+		req.RAMMB = mb
+	}
+
+	switch req.Prefer {
+	case "":
+		req.Prefer = "cpu"
+	case "cpu", "ram":
+	default:
+		return req, fmt.Errorf("invalid --prefer %q; must be cpu or ram", prefer)
+	}
+	return req, nil
+}
+
+// parseRAMMB() parses a --ram value in MB: a size with a K, M, G or T
+// suffix, or a plain number of MB
+func parseRAMMB(s string) (int, error) {
+	if mb, err := strconv.Atoi(s); err == nil && mb >= 0 {
+		return mb, nil
+	}
+	bytes, err := parseByteSize(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --ram: %v", err)
+	}
+	return int(bytes / (1024 * 1024)), nil
+}
+
+// matchFlavors() returns the enabled public flavors that fit req, the
+// smallest first. Flavors are ordered by vCPUs then RAM with --prefer
+// cpu, by RAM then vCPUs with ram, then by disk and name.
+func matchFlavors(computeURL string, req flavorRequest) ([]api.FlavorDetail, error) {
+	flavors, err := api.ListFlavorsDetail(computeURL, tok.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []api.FlavorDetail
+	for _, f := range flavors {
+		if f.IsDisabled || !f.IsPublic || f.Disk < req.DiskGB {
+			continue
+		}
+		if req.Exact && ((req.VCPUs > 0 && f.VCPUs != req.VCPUs) || (req.RAMMB > 0 && f.RAM != req.RAMMB)) {
+			continue
+		}
+		if f.VCPUs >= req.VCPUs && f.RAM >= req.RAMMB {
+			candidates = append(candidates, f)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no public flavor with %s", req)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		first, second := [2]int{a.VCPUs, b.VCPUs}, [2]int{a.RAM, b.RAM}
+		if req.Prefer == "ram" {
+			first, second = second, first
+		}
+		if first[0] != first[1] {
+			return first[0] < first[1]
+		}
+		if second[0] != second[1] {
+			return second[0] < second[1]
+		}
+		if a.Disk != b.Disk {
+			return a.Disk < b.Disk
+		}
+		return natsort.Compare(a.Name, b.Name)
+	})
+	return candidates, nil
+}
+
+// closestFlavor() returns the flavor matchFlavors() picks for req
+func closestFlavor(computeURL string, req flavorRequest) (api.FlavorDetail, error) {
+	flavors, err := matchFlavors(computeURL, req)
+	if err != nil {
+		return api.FlavorDetail{}, fmt.Errorf("%v; pass --flavor", err)
+	}
+	return flavors[0], nil
+}
+
+// addFlavorMatchFlags() registers the --cpus, --ram, --exact and --prefer
+// flags of a command that picks a flavor
+func addFlavorMatchFlags(cmd *cobra.Command, cpus *int, ram *string, exact *bool, prefer *string) {
+	cmd.Flags().IntVar(cpus, "cpus", 0, "Pick the smallest public flavor with at least this many vCPUs")
+	cmd.Flags().StringVar(ram, "ram", "", "Pick the smallest public flavor with at least this much RAM, e.g. 8G (plain numbers are MB)")
+	cmd.Flags().BoolVar(exact, "exact", false, "Only pick a flavor with exactly --cpus and --ram")
+	cmd.Flags().StringVar(prefer, "prefer", "cpu", "Between larger flavors, prefer the one closest in: cpu or ram")
+}
+
+var (
+	flagFlavorCPUs   int
+	flagFlavorRAM    string
+	flagFlavorDisk   int
+	flagFlavorExact  bool
+	flagFlavorPrefer string
+
+	flagFlavorID          string
+	flagFlavorEphemeral   int
+	flagFlavorSwap        int
+	flagFlavorPrivate     bool
+	flagFlavorDescription string
+	flagFlavorSpecs       []string
+	flagFlavorProjects    []string
+
+	flagFlavorProjectDomain string
+)
+
+func init() {
+	addFlavorMatchFlags(flavorMatchCmd, &flagFlavorCPUs, &flagFlavorRAM, &flagFlavorExact, &flagFlavorPrefer)
+	flavorMatchCmd.Flags().IntVar(&flagFlavorDisk, "disk", 0, "Minimum flavor root disk in GB")
+	flavorMatchCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+	flavorMatchCmd.MarkFlagsOneRequired("cpus", "ram")
+
+	flavorCreateCmd.Flags().IntVar(&flagFlavorCPUs, "vcpus", 0, "Number of vCPUs")
+	flavorCreateCmd.Flags().StringVar(&flagFlavorRAM, "ram", "", "RAM, e.g. 16G (plain numbers are MB)")
+	flavorCreateCmd.Flags().IntVar(&flagFlavorDisk, "disk", 0, "Root disk in GB (0 for volume-backed VMs)")
+	flavorCreateCmd.Flags().IntVar(&flagFlavorEphemeral, "ephemeral", 0, "Ephemeral disk in GB")
+	flavorCreateCmd.Flags().IntVar(&flagFlavorSwap, "swap", 0, "Swap disk in MB")
+	flavorCreateCmd.Flags().StringVar(&flagFlavorID, "id", "", "Flavor ID (default: generated)")
+	flavorCreateCmd.Flags().BoolVar(&flagFlavorPrivate, "private", false, "Only projects given access can use the flavor")
+	flavorCreateCmd.Flags().StringVar(&flagFlavorDescription, "description", "", "Flavor description")
+	flavorCreateCmd.Flags().StringArrayVar(&flagFlavorSpecs, "spec", nil, "Extra spec, repeatable: key=value")
+	flavorCreateCmd.Flags().StringArrayVar(&flagFlavorProjects, "project", nil, "Project (name or ID) to give access to a --private flavor, repeatable")
+	flavorCreateCmd.Flags().StringVar(&flagFlavorProjectDomain, "project-domain", "", "Domain to look --project names up in")
+	flavorCreateCmd.MarkFlagRequired("vcpus")
+	flavorCreateCmd.MarkFlagRequired("ram")
+
+	flavorAccessListCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+	for _, c := range []*cobra.Command{flavorAccessAddCmd, flavorAccessRemoveCmd} {
+		c.Flags().StringVar(&flagFlavorProjectDomain, "project-domain", "", "Domain to look the project name up in")
+	}
+
+	flavorAccessCmd.AddCommand(flavorAccessListCmd)
+	flavorAccessCmd.AddCommand(flavorAccessAddCmd)
+	flavorAccessCmd.AddCommand(flavorAccessRemoveCmd)
+
+	flavorCmd.AddCommand(flavorMatchCmd)
+	flavorCmd.AddCommand(flavorCreateCmd)
+	flavorCmd.AddCommand(flavorDeleteCmd)
+	flavorCmd.AddCommand(flavorSetSpecCmd)
+	flavorCmd.AddCommand(flavorUnsetSpecCmd)
+	flavorCmd.AddCommand(flavorAccessCmd)
+	rootCmd.AddCommand(flavorCmd)
+}
@@ -0,0 +1,380 @@
+package cmd
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/responseparser"
+	"github.com/spf13/cobra"
+)
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage images",
+}
+
+var imageDownloadCmd = &cobra.Command{
+	Use:   "download <image>",
+	Short: "Download an image to a local file",
+	Long: `Download an image's data to a local file and verify it against the
+checksum Glance recorded at upload (os_hash_value, or the legacy md5 checksum).
+
+If the output file already exists and is smaller than the image, the download
+resumes where it stopped. Use --force to start over.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imageURL, err := validateTokenEndpoint(tok, "image")
+		if err != nil {
+			return err
+		}
+
+		imageID := resolveImageID(imageURL, args[0])
+
+		img, err := api.GetImageDetails(imageURL, tok.Value, imageID)
+		if err != nil {
+			return err
+		}
+		if img.Status != "active" {
+			return fmt.Errorf("image %s is %s; only active images can be downloaded", imageID, img.Status)
+		}
+
+		outPath := flagImageOutput
+		if outPath == "" {
+			outPath = img.Name
+			if img.DiskFormat != "" {
+				outPath = fmt.Sprintf("%s.%s", img.Name, img.DiskFormat)
+			}
+		}
+
+		hasher, expected, algo := imageHasher(img)
+
+		flags := os.O_CREATE | os.O_RDWR
+		if flagImageForce {
+			flags |= os.O_TRUNC
+		}
+		file, err := os.OpenFile(outPath, flags, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open output file: %v", err)
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return fmt.Errorf("failed to stat output file: %v", err)
+		}
+		offset := info.Size()
+		if offset > img.Size {
+			return fmt.Errorf("%s is larger than the image (%d > %d bytes); use --force to overwrite", outPath, offset, img.Size)
+		}
+
+		// Feed the already downloaded part through the hasher before resuming
+		if offset > 0 {
+			fmt.Printf("Resuming download of %s at %d MB\n", outPath, offset/1024/1024)
+			if hasher != nil {
+				if _, err := io.Copy(hasher, file); err != nil {
+					return fmt.Errorf("failed to read partial download: %v", err)
+				}
+			}
+		}
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek output file: %v", err)
+		}
+
+		if offset < img.Size {
+			fmt.Printf("Downloading image %s (%d MB) to %s\n", img.Name, img.Size/1024/1024, outPath)
+
+			var w io.Writer = file
+			if hasher != nil {
+				w = io.MultiWriter(file, hasher)
+			}
+			if err := api.DownloadImageData(imageURL, tok.Value, imageID, w, offset, img.Size); err != nil {
+				return fmt.Errorf("%v; rerun the same command to resume", err)
+			}
+		}
+
+		if hasher == nil {
+			fmt.Printf("Image downloaded to %s (no checksum recorded, not verified)\n", outPath)
+			return nil
+		}
+
+		actual := hex.EncodeToString(hasher.Sum(nil))
+		if actual != expected {
+			return fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s", algo, outPath, expected, actual)
+		}
+
+		fmt.Printf("Image downloaded to %s (%s verified)\n", outPath, algo)
+		return nil
+	},
+}
+
+var imageSetCmd = &cobra.Command{
+	Use:   "set <image> key=value [key=value...]",
+	Short: "Set image properties",
+	Long: `Set core or custom image properties, for example:
+  vhicmd image set myimage hw_disk_bus=scsi hw_firmware_type=uefi
+  vhicmd image set myimage hw_qemu_guest_agent=yes os_type=linux min_disk=20 min_ram=2048`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imageURL, err := validateTokenEndpoint(tok, "image")
+		if err != nil {
+			return err
+		}
+
+		props, err := parseKeyValues(args[1:])
+		if err != nil {
+			return err
+		}
+
+		imageID := resolveImageID(imageURL, args[0])
+		if err := api.SetImageProperties(imageURL, tok.Value, imageID, props); err != nil {
+			return err
+		}
+
+		for key, value := range props {
+			fmt.Printf("Set %s = %s on image %s\n", key, value, imageID)
+		}
+		return nil
+	},
+}
+
+var imageUnsetCmd = &cobra.Command{
+	Use:   "unset <image> key [key...]",
+	Short: "Remove custom image properties",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imageURL, err := validateTokenEndpoint(tok, "image")
+		if err != nil {
+			return err
+		}
+
+		imageID := resolveImageID(imageURL, args[0])
+		if err := api.UnsetImageProperties(imageURL, tok.Value, imageID, args[1:]); err != nil {
+			return err
+		}
+
+		for _, key := range args[1:] {
+			fmt.Printf("Removed %s from image %s\n", key, imageID)
+		}
+		return nil
+	},
+}
+
+var imageMemberCmd = &cobra.Command{
+	Use:   "member",
+	Short: "Share images with other projects",
+}
+
+var imageMemberListCmd = &cobra.Command{
+	Use:   "list <image>",
+	Short: "List projects an image is shared with",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imageURL, err := validateTokenEndpoint(tok, "image")
+		if err != nil {
+			return err
+		}
+
+		imageID := resolveImageID(imageURL, args[0])
+		resp, err := api.ListImageMembers(imageURL, tok.Value, imageID)
+		if err != nil {
+			return err
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(resp.Members, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+
+		var memberList []responseparser.ImageMember
+		for _, m := range resp.Members {
+			memberList = append(memberList, responseparser.ImageMember{
+				MemberID:  m.MemberID,
+				Status:    m.Status,
+				CreatedAt: m.CreatedAt,
+				UpdatedAt: m.UpdatedAt,
+			})
+		}
+		responseparser.PrintImageMembersTable(memberList)
+		return nil
+	},
+}
+
+var imageMemberAddCmd = &cobra.Command{
+	Use:   "add <image> <project>",
+	Short: "Share an image with a project",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imageURL, err := validateTokenEndpoint(tok, "image")
+		if err != nil {
+			return err
+		}
+
+		imageID := resolveImageID(imageURL, args[0])
+		projectID := resolveProjectID(args[1])
+
+		img, err := api.GetImageDetails(imageURL, tok.Value, imageID)
+		if err != nil {
+			return err
+		}
+		if img.Visibility != "shared" {
+			fmt.Printf("Changing visibility of image %s from %s to shared...\n", imageID, img.Visibility)
+			if err := api.SetImageProperties(imageURL, tok.Value, imageID, map[string]string{"visibility": "shared"}); err != nil {
+				return err
+			}
+		}
+
+		member, err := api.AddImageMember(imageURL, tok.Value, imageID, projectID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Image %s shared with project %s (status: %s)\n", imageID, member.MemberID, member.Status)
+		fmt.Printf("The project must accept it with: vhicmd image member accept %s\n", imageID)
+		return nil
+	},
+}
+
+var imageMemberRemoveCmd = &cobra.Command{
+	Use:   "remove <image> <project>",
+	Short: "Stop sharing an image with a project",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imageURL, err := validateTokenEndpoint(tok, "image")
+		if err != nil {
+			return err
+		}
+
+		imageID := resolveImageID(imageURL, args[0])
+		projectID := resolveProjectID(args[1])
+
+		if err := api.RemoveImageMember(imageURL, tok.Value, imageID, projectID); err != nil {
+			return err
+		}
+
+		fmt.Printf("Image %s no longer shared with project %s\n", imageID, projectID)
+		return nil
+	},
+}
+
+var imageMemberAcceptCmd = &cobra.Command{
+	Use:   "accept <image_id>",
+	Short: "Accept an image shared with the current project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		imageURL, err := validateTokenEndpoint(tok, "image")
+		if err != nil {
+			return err
+		}
+
+		// shared images don't show up in listings until accepted, so no name lookup here
+		imageID := args[0]
+		projectID := currentProjectID()
+
+		if err := api.UpdateImageMemberStatus(imageURL, tok.Value, imageID, projectID, "accepted"); err != nil {
+			return err
+		}
+
+		fmt.Printf("Image %s accepted into project %s\n", imageID, tok.Project)
+		return nil
+	},
+}
+
+// resolveImageID returns the ID of the image matching nameOrID by name,
+// or nameOrID unchanged if no single image matches.
+func resolveImageID(imageURL, nameOrID string) string {
+	id, err := api.GetImageIDByName(imageURL, tok.Value, nameOrID)
+	if err == nil {
+		return id
+	}
+	return nameOrID
+}
+
+// resolveProjectID returns the ID of the project named nameOrID,
+// or nameOrID unchanged if it can't be looked up.
+func resolveProjectID(nameOrID string) string {
+	identityURL, err := validateTokenEndpoint(tok, "identity")
+	if err != nil {
+		return nameOrID
+	}
+	id, err := api.GetProjectIDByName(identityURL, tok.Value, nameOrID)
+	if err == nil {
+		return id
+	}
+	return nameOrID
+}
+
+// currentProjectID returns the ID of the token's project. Tokens saved
+// without it fall back to looking the name up, which needs Keystone
+// project listing that non-admin users usually lack.
+func currentProjectID() string {
+	if tok.ProjectID != "" {
+		return tok.ProjectID
+	}
+	return resolveProjectID(tok.Project)
+}
+
+// parseKeyValues() splits key=value arguments into a map
+func parseKeyValues(pairs []string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid property %q; expected key=value", pair)
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+// imageHasher() returns a hash matching the image's recorded checksum,
+// the expected hex digest and the algorithm name, or a nil hash if the
+// image has no usable checksum
+func imageHasher(img api.Image) (hash.Hash, string, string) {
+	if img.OSHashValue != "" {
+		switch img.OSHashAlgo {
+		case "sha512":
+			return sha512.New(), img.OSHashValue, img.OSHashAlgo
+		case "sha384":
+			return sha512.New384(), img.OSHashValue, img.OSHashAlgo
+		case "sha256":
+			return sha256.New(), img.OSHashValue, img.OSHashAlgo
+		}
+	}
+	if img.Checksum != "" {
+		return md5.New(), img.Checksum, "md5"
+	}
+	return nil, "", ""
+}
+
+var (
+	flagImageOutput string
+	flagImageForce  bool
+)
+
+func init() {
+	imageDownloadCmd.Flags().StringVarP(&flagImageOutput, "output", "o", "", "Output file (default: <image name>.<disk format>)")
+	imageDownloadCmd.Flags().BoolVar(&flagImageForce, "force", false, "Overwrite the output file instead of resuming")
+
+	imageMemberListCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+
+	imageMemberCmd.AddCommand(imageMemberListCmd)
+	imageMemberCmd.AddCommand(imageMemberAddCmd)
+	imageMemberCmd.AddCommand(imageMemberRemoveCmd)
+	imageMemberCmd.AddCommand(imageMemberAcceptCmd)
+
+	imageCmd.AddCommand(imageDownloadCmd)
+	imageCmd.AddCommand(imageSetCmd)
+	imageCmd.AddCommand(imageUnsetCmd)
+	imageCmd.AddCommand(imageMemberCmd)
+	rootCmd.AddCommand(imageCmd)
+}
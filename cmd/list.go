@@ -418,6 +418,40 @@ var listVolumesCmd = &cobra.Command{
 	},
 }
 
+var listVolumeTypesCmd = &cobra.Command{
+	Use:   "volume-types",
+	Short: "List volume types (storage policies)",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageURL, err := validateTokenEndpoint(tok, "volumev3")
+		if err != nil {
+			return err
+		}
+
+		resp, err := api.ListVolumeTypes(storageURL, tok.Value)
+		if err != nil {
+			return err
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(resp.VolumeTypes, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+
+		var typeList []responseparser.VolumeType
+		for _, t := range resp.VolumeTypes {
+			typeList = append(typeList, responseparser.VolumeType{
+				ID:          t.ID,
+				Name:        t.Name,
+				Description: t.Description,
+				IsPublic:    t.IsPublic,
+			})
+		}
+		responseparser.PrintVolumeTypesTable(typeList)
+		return nil
+	},
+}
+
 func init() {
 	listCmd.PersistentFlags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
 
@@ -458,5 +492,6 @@ func init() {
 	listCmd.AddCommand(listVmCmd)
 	listCmd.AddCommand(listImagesCmd)
 	listCmd.AddCommand(listVolumesCmd)
+	listCmd.AddCommand(listVolumeTypesCmd)
 	rootCmd.AddCommand(listCmd)
 }
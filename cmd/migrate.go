@@ -3,13 +3,18 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/diskimage"
+	"github.com/jessegalley/vhicmd/internal/vmconfig"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
 )
 
 // =========================
@@ -22,8 +27,9 @@ import (
 // the specified networks with the specified MAC addresses.
 //
 // TO PREVENT COLLISIONS:
-// Ensure the vSphere VM is powered off before migration, or use the --shutdown
-// flag to shut down the VM after migration.
+// Ensure the vSphere VM is powered off before migration, or pass --source-vm
+// with vCenter credentials in config to have it powered off before the upload.
+// The --shutdown flag shuts down the new VHI VM after migration.
 
 // 'migrate' parent command
 var migrateCmd = &cobra.Command{
@@ -34,8 +40,20 @@ var migrateCmd = &cobra.Command{
 // 'migrate vm' subcommand
 var migrateVMCmd = &cobra.Command{
 	Use:   "vm",
-	Short: "Migrate a virtual machine from a VMWare VMDK",
-	Long: `Example:
+	Short: "Migrate a virtual machine from a VMWare VMDK or VMX",
+	Long: `Pass the VMDK descriptor (the small .vmdk); its extents are resolved
+(flat, VMFS, split 2GB flat or monolithic sparse), the disk bus is taken from
+ddb.adapterType and the root volume is sized to the disk's capacity. Disks
+with unconsolidated snapshot deltas are refused.
+
+With --vmx, the name, vCPUs, memory, every disk and every NIC (with its MAC)
+are read from the VM's .vmx file. The closest flavor is picked unless --flavor
+is given, and vSphere portgroups are translated to VHI networks with a YAML
+mapping file (--network-map), e.g.:
+  "VM Network": public
+  DMZ-VLAN20: dmz
+
+Example:
   vhicmd migrate vm \
     --name MyVM \
     --vmdk /path/to/disk.vmdk \
@@ -43,269 +61,909 @@ var migrateVMCmd = &cobra.Command{
     --networks netA,netB,netC \
     --mac auto,bb:bb:bb:bb:bb:bb,auto \
     --size 20 \
-    --shutdown`,
+    --shutdown
+
+  vhicmd migrate vm --vmx /mnt/vmdk/ds1/myvm/myvm.vmx --network-map portgroups.yaml
+  vhicmd migrate vm --libvirt-xml guest.xml --network-map bridges.yaml
+
+With --ova, the VM is read from the appliance's OVF (vCPUs, memory, disks,
+NICs) and each disk is streamed to Glance straight out of the archive, so
+streamOptimized VMDKs need no unpacking or conversion. OVF network names are
+mapped with --network-map as for --vmx.
+
+With --libvirt-xml, a KVM guest is read from its domain XML (virsh dumpxml):
+vCPUs, memory, its qcow2 or raw disks (files or block devices) on the same
+bus, and each interface keeps its MAC on the VHI network its bridge maps to
+in --network-map. Shut the guest down first.
+
+With --source-vm, the vSphere VM is shut down through the vCenter from
+config (vcenter_host, vcenter_username, vcenter_password), powered off hard
+if it doesn't stop within --source-timeout, and its disks are checked to be
+unlocked and unchanging before the upload starts.
+
+Use --preflight (or 'migrate check') to only run the pre-flight checks.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		computeURL, err := validateTokenEndpoint(tok, "compute")
+		spec, err := migrationSpecFromFlags()
 		if err != nil {
 			return err
 		}
-		imageURL, err := validateTokenEndpoint(tok, "image")
-		if err != nil {
-			return err
+
+		if migrateFlagPreflight {
+			return runPreflight(spec, os.Stdout)
 		}
-		networkURL, err := validateTokenEndpoint(tok, "network")
+
+		summary, err := runMigration(spec, os.Stdout)
 		if err != nil {
 			return err
 		}
 
-		if migrateFlagVMName == "" {
-			return fmt.Errorf("must provide --name for the VM")
-		}
-		if migrateFlagVMDKPath == "" {
-			return fmt.Errorf("must provide --vmdk /path/to/image for migration")
-		}
+		data, _ := json.MarshalIndent(summary, "", "  ")
+		fmt.Println(string(data))
 
-		// validate disk bus
-		if migrateFlagDiskBus != "sata" && migrateFlagDiskBus != "scsi" && migrateFlagDiskBus != "virtio" {
-			return fmt.Errorf("disk bus must be one of: sata, scsi, virtio")
-		}
+		return nil
+	},
+}
 
-		flavorRef := migrateFlagFlavorRef
-		if flavorRef == "" {
-			flavorRef = viper.GetString("flavor_id")
+// migrationSpecFromFlags() builds the spec of 'migrate vm' and 'migrate
+// check' from their flags, the .vmx and config defaults
+func migrationSpecFromFlags() (migrationSpec, error) {
+	computeURL, err := validateTokenEndpoint(tok, "compute")
+	if err != nil {
+		return migrationSpec{}, err
+	}
+
+	spec := migrationSpec{
+		Name:       migrateFlagVMName,
+		Flavor:     migrateFlagFlavorRef,
+		Size:       migrateFlagVMSize,
+		DiskBus:    migrateFlagDiskBus,
+		VolumeType: migrateFlagVolumeType,
+		Shutdown:   migrateFlagShutdown,
+		Resume:     migrateFlagResume,
+		Sparse:     migrateFlagSparse,
+
+		SourceVM:      migrateFlagSourceVM,
+		SourceTimeout: migrateFlagSourceTimeout,
+	}
+	if migrateFlagNetworkCSV != "" {
+		spec.Networks = strings.Split(migrateFlagNetworkCSV, ",")
+	}
+	if migrateFlagMacAddrCSV != "" {
+		spec.MACs = strings.Split(migrateFlagMacAddrCSV, ",")
+	}
+
+	spec.Upload, err = uploadOptionsFromFlags(migrateFlagBwLimit, migrateFlagRetries, migrateFlagStage)
+	if err != nil {
+		return spec, err
+	}
+	spec.FlavorMatch, err = flavorRequestFromFlags(migrateFlagCPUs, migrateFlagRAM, migrateFlagExact, migrateFlagPrefer)
+	if err != nil {
+		return spec, err
+	}
+
+	switch {
+	case migrateFlagVMX != "":
+		if err := specFromVMX(computeURL, migrateFlagVMX, migrateFlagNetworkMap, &spec, os.Stdout); err != nil {
+			return spec, err
 		}
-		if flavorRef == "" {
-			return fmt.Errorf("no flavor specified; provide --flavor or set 'flavor_id' in config")
+	case migrateFlagOVA != "":
+		if err := specFromOVA(computeURL, migrateFlagOVA, migrateFlagNetworkMap, &spec, os.Stdout); err != nil {
+			return spec, err
 		}
-
-		networks := migrateFlagNetworkCSV
-		if networks == "" {
-			networks = viper.GetString("networks")
+	case migrateFlagLibvirtXML != "":
+		if err := specFromLibvirt(computeURL, migrateFlagLibvirtXML, migrateFlagNetworkMap, &spec, os.Stdout); err != nil {
+			return spec, err
+		}
+	case migrateFlagVMDKPath != "":
+		spec.Disks = []vmconfig.Disk{{Path: migrateFlagVMDKPath}}
+		if spec.Flavor == "" && (spec.FlavorMatch.VCPUs > 0 || spec.FlavorMatch.RAMMB > 0) {
+			flavor, err := closestFlavor(computeURL, spec.FlavorMatch)
+			if err != nil {
+				return spec, err
+			}
+			fmt.Printf("Using flavor %s (%d vCPU, %d MB RAM)\n", flavor.Name, flavor.VCPUs, flavor.RAM)
+			spec.Flavor = flavor.ID
 		}
-		if networks == "" {
-			return fmt.Errorf("no networks specified; provide --networks or set 'networks' in config")
+		if spec.Flavor == "" {
+			spec.Flavor = viper.GetString("flavor_id")
 		}
-
-		macs := migrateFlagMacAddrCSV
-		networkIDs := strings.Split(networks, ",")
-		macAddresses := strings.Split(macs, ",")
-		if len(networkIDs) != len(macAddresses) {
-			return fmt.Errorf("the number of networks must match the number of MAC addresses")
+		if spec.Networks == nil && viper.GetString("networks") != "" {
+			spec.Networks = strings.Split(viper.GetString("networks"), ",")
 		}
-		for _, mac := range macAddresses {
-			if err := validateMacAddr(mac); err != nil {
-				return fmt.Errorf("invalid MAC address: %s", err)
+		if len(spec.Networks) == 0 {
+			return spec, fmt.Errorf("no networks specified; provide --networks or set 'networks' in config")
+		}
+		if spec.MACs == nil {
+			for range spec.Networks {
+				spec.MACs = append(spec.MACs, "auto")
 			}
 		}
+	default:
+		return spec, fmt.Errorf("must provide --vmdk, --vmx, --ova or --libvirt-xml for migration")
+	}
+
+	if spec.Name == "" {
+		return spec, fmt.Errorf("must provide --name for the VM")
+	}
+	if spec.Flavor == "" {
+		return spec, fmt.Errorf("no flavor specified; provide --flavor or set 'flavor_id' in config")
+	}
+
+	return spec, nil
+}
 
-		fid, err := api.GetFlavorIDByName(computeURL, tok.Value, flavorRef)
-		if err == nil && fid != "" {
-			flavorRef = fid
-		}
+// migrationSpec describes one VM to migrate
+type migrationSpec struct {
+	Name        string
+	Disks       []vmconfig.Disk // boot disk first; an empty Bus comes from the descriptor
+	OVA         string          // archive the disks are read from, if any
+	Flavor      string          // name or ID
+	FlavorMatch flavorRequest   // picks the flavor if none is set; zero sizes come from the source VM
+	Networks    []string        // names or IDs
+	MACs        []string        // one per network, "auto" to let Neutron pick
+	Size        int64           // root volume size in GB, 0 for the disk capacity
+	DiskBus     string          // overrides the bus of every disk
+	VolumeType  string
+	Shutdown    bool
+	Resume      bool // continue a previous failed run from its state file
+	Sparse      bool // upload raw disks as qcow2 without their holes and zeroes
+	Upload      api.UploadOptions
+
+	SourceVM      string        // vSphere VM to power off before the upload
+	SourceTimeout time.Duration // graceful shutdown timeout of SourceVM
+}
+
+// migrationResult summarizes a migrated VM
+type migrationResult struct {
+	VMID       string          `json:"vm_id"`
+	VMName     string          `json:"vm_name"`
+	PowerState string          `json:"power_state,omitempty"`
+	Networks   []migrationPort `json:"networks"`
+}
 
-		fmt.Printf("Creating temporary image for VM '%s'...\n", migrateFlagVMName)
+// migrationPort is a port created for a migrated VM
+type migrationPort struct {
+	NetworkID  string `json:"network_id"`
+	MACAddress string `json:"mac_address"`
+}
+
+// migrationDisk is an opened source disk ready for upload
+type migrationDisk struct {
+	desc    *diskimage.VMDKDescriptor // nil for a disk inside an OVA
+	name    string                    // shown in messages
+	data    io.ReadSeekCloser
+	format  string // Glance disk_format of data
+	size    int64  // bytes of data
+	bus     string
+	sizeGB  int64
+	imageID string
+}
 
-		file, err := os.Open(migrateFlagVMDKPath)
+// specFromVMX() fills spec from a .vmx file; values already set from
+// flags take precedence
+func specFromVMX(computeURL, vmxPath, networkMapPath string, spec *migrationSpec, out io.Writer) error {
+	vm, err := vmconfig.ParseVMX(vmxPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "VMX: %s, %d vCPU, %d MB RAM, %d disk(s), %d NIC(s)\n",
+		vm.Name, vm.VCPUs, vm.MemoryMB, len(vm.Disks), len(vm.NICs))
+	return specFromVM(computeURL, vm, vmxPath, networkMapPath, spec, out)
+}
+
+// specFromOVA() fills spec from the OVF descriptor of an OVA; values
+// already set from flags take precedence
+func specFromOVA(computeURL, ovaPath, networkMapPath string, spec *migrationSpec, out io.Writer) error {
+	ova, err := vmconfig.OpenOVA(ovaPath)
+	if err != nil {
+		return err
+	}
+	defer ova.Close()
+
+	vm, err := ova.VM()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "OVA: %s, %d vCPU, %d MB RAM, %d disk(s), %d NIC(s)\n",
+		vm.Name, vm.VCPUs, vm.MemoryMB, len(vm.Disks), len(vm.NICs))
+	spec.OVA = ovaPath
+	return specFromVM(computeURL, vm, ovaPath, networkMapPath, spec, out)
+}
+
+// specFromLibvirt() fills spec from a libvirt domain XML; values already
+// set from flags take precedence
+func specFromLibvirt(computeURL, xmlPath, networkMapPath string, spec *migrationSpec, out io.Writer) error {
+	vm, err := vmconfig.ParseLibvirtXML(xmlPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Domain XML: %s, %d vCPU, %d MB RAM, %d disk(s), %d NIC(s)\n",
+		vm.Name, vm.VCPUs, vm.MemoryMB, len(vm.Disks), len(vm.NICs))
+	return specFromVM(computeURL, vm, xmlPath, networkMapPath, spec, out)
+}
+
+// specFromVM() fills spec from the hardware of a source VM read from
+// source: the closest flavor, the networks its NICs map to and their MACs
+func specFromVM(computeURL string, vm vmconfig.VM, source, networkMapPath string, spec *migrationSpec, out io.Writer) error {
+	if spec.Name == "" {
+		spec.Name = vm.Name
+	}
+	spec.Disks = vm.Disks
+
+	if spec.Flavor == "" {
+		req := spec.FlavorMatch
+		if req.VCPUs == 0 {
+			req.VCPUs = vm.VCPUs
+		}
+		if req.RAMMB == 0 {
+			req.RAMMB = vm.MemoryMB
+		}
+		flavor, err := closestFlavor(computeURL, req)
 		if err != nil {
-			return fmt.Errorf("failed to open image file: %v", err)
+			return err
 		}
-		defer file.Close()
+		fmt.Fprintf(out, "Using flavor %s (%d vCPU, %d MB RAM)\n", flavor.Name, flavor.VCPUs, flavor.RAM)
+		spec.Flavor = flavor.ID
+	}
 
-		info, err := file.Stat()
+	if spec.Networks == nil && len(vm.NICs) > 0 {
+		if networkMapPath == "" {
+			return fmt.Errorf("%s has %d NIC(s); provide --networks or --network-map", source, len(vm.NICs))
+		}
+		networkMap, err := loadNetworkMap(networkMapPath)
 		if err != nil {
-			return fmt.Errorf("failed to stat file: %v", err)
+			return err
 		}
 
-		fmt.Printf("Starting upload of %s (%d MB)\n", migrateFlagVMDKPath, info.Size()/1024/1024)
+		var unmapped []string
+		for _, nic := range vm.NICs {
+			network, ok := networkMap[nic.Network]
+			if !ok {
+				unmapped = append(unmapped, fmt.Sprintf("%q (%s)", nic.Network, nic.Device))
+				continue
+			}
+			spec.Networks = append(spec.Networks, network)
+		}
+		if len(unmapped) > 0 {
+			return fmt.Errorf("no mapping in %s for portgroup(s): %s", networkMapPath, strings.Join(unmapped, ", "))
+		}
+	}
 
-		imgReq := api.CreateImageRequest{
-			Name:         fmt.Sprintf("Migrated-%s", migrateFlagVMName),
-			ContainerFmt: "bare",
-			DiskFmt:      "vmdk",
-			Visibility:   "shared",
+	if spec.MACs == nil {
+		for _, nic := range vm.NICs {
+			mac := nic.MAC
+			if mac == "" {
+				mac = "auto"
+			}
+			spec.MACs = append(spec.MACs, mac)
 		}
+	}
+	return nil
+}
 
-		imageID, err := api.CreateAndUploadImage(imageURL, tok.Value, imgReq, file)
+// loadNetworkMap() reads a YAML file mapping source network names
+// (vSphere portgroups, bridges) to VHI network names or IDs
+func loadNetworkMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read network map: %v", err)
+	}
+
+	networkMap := make(map[string]string)
+	if err := yaml.Unmarshal(data, &networkMap); err != nil {
+		return nil, fmt.Errorf("failed to parse network map %s: %v", path, err)
+	}
+	return networkMap, nil
+}
+
+// runMigration() uploads the disks of spec as temporary images, creates
+// the VM with a volume for every disk, attaches its ports and removes the
+// images again. Each completed stage is recorded in a state file so that
+// spec.Resume can pick up after a failure. The result is filled in as far
+// as the migration got.
+func runMigration(spec migrationSpec, out io.Writer) (migrationResult, error) {
+	state, err := startMigrationState(spec)
+	if err != nil {
+		return migrationResult{VMName: spec.Name, Networks: []migrationPort{}}, err
+	}
+	if state.Stage != stageStarted {
+		fmt.Fprintf(out, "Resuming migration of %s after stage %s\n", spec.Name, state.Stage)
+	}
+
+	result, err := migrateVM(spec, state, out)
+	state.finish(err)
+	return result, err
+}
+
+// migrateVM() runs the stages of a migration not yet done in state
+func migrateVM(spec migrationSpec, state *migrationState, out io.Writer) (migrationResult, error) {
+	result := migrationResult{VMName: spec.Name, Networks: []migrationPort{}}
+
+	computeURL, err := validateTokenEndpoint(tok, "compute")
+	if err != nil {
+		return result, err
+	}
+	imageURL, err := validateTokenEndpoint(tok, "image")
+	if err != nil {
+		return result, err
+	}
+	networkURL, err := validateTokenEndpoint(tok, "network")
+	if err != nil {
+		return result, err
+	}
+
+	if len(spec.Networks) != len(spec.MACs) {
+		return result, fmt.Errorf("the number of networks must match the number of MAC addresses")
+	}
+	for _, mac := range spec.MACs {
+		if err := validateMacAddr(strings.TrimSpace(mac)); err != nil {
+			return result, fmt.Errorf("invalid MAC address: %s", err)
+		}
+	}
+
+	volumeType := spec.VolumeType
+	if volumeType == "" {
+		volumeType = viper.GetString("volume_type")
+	}
+
+	flavorRef := spec.Flavor
+	fid, err := api.GetFlavorIDByName(computeURL, tok.Value, flavorRef)
+	if err == nil && fid != "" {
+		flavorRef = fid
+	}
+
+	// Resolve every descriptor to its extents before anything is created,
+	// so snapshot deltas and unsupported layouts fail early
+	var disks []*migrationDisk
+	defer func() {
+		for _, d := range disks {
+			d.data.Close()
+		}
+	}()
+	for i, src := range spec.Disks {
+		d, err := openSpecDisk(spec, src, out)
 		if err != nil {
-			return fmt.Errorf("failed to create/upload image: %v", err)
+			return result, err
 		}
+		disks = append(disks, d)
 
-		imageSize, err := api.GetImageSize(imageURL, tok.Value, imageID)
-		if err != nil {
-			return fmt.Errorf("failed to get image size: %v", err)
+		if i == 0 && spec.Size != 0 {
+			if spec.Size < d.sizeGB {
+				return result, fmt.Errorf("--size %d GB is smaller than the disk capacity (%d GB)", spec.Size, d.sizeGB)
+			}
+			d.sizeGB = spec.Size
 		}
+	}
+
+	// A VM left by a previous run is reused as long as it still exists
+	if state.VMID != "" {
+		if _, err := api.GetVMDetails(computeURL, tok.Value, state.VMID); err != nil {
+			fmt.Fprintf(out, "VM %s from the previous run is gone, creating it again\n", state.VMID)
+			state.VMID = ""
+			for i := range state.Ports {
+				state.Ports[i].Attached = false
+			}
+		}
+	}
 
-		imageSizeGB := int64(0)
-		if migrateFlagVMSize == 0 {
-			// round up to the nearest GB
-			imageSizeGB = (imageSize + 1024*1024*1024 - 1) / (1024 * 1024 * 1024)
-		} else {
-			imageSizeGB = migrateFlagVMSize
+	if state.VMID == "" {
+		var totalGB int64
+		for _, d := range disks {
+			totalGB += d.sizeGB
+		}
+		ports := max(len(spec.Networks)-len(state.Ports), 0)
+		if err := checkVMQuota(flavorRef, len(disks), totalGB, volumeType, ports); err != nil {
+			return result, err
 		}
 
-		fmt.Printf("\nImage created: %s\n", imageID)
+		if spec.SourceVM != "" && state.Stage == stageStarted {
+			if err := stopSourceVM(spec.SourceVM, disks, spec.SourceTimeout, out); err != nil {
+				return result, fmt.Errorf("failed to stop source VM: %v", err)
+			}
+			state.SourceStopped = true
+			if err := state.save(); err != nil {
+				return result, err
+			}
+		}
+
+		for i, d := range disks {
+			if id := state.Disks[i].ImageID; id != "" {
+				img, err := api.GetImageDetails(imageURL, tok.Value, id)
+				if err == nil && img.Status == "active" {
+					fmt.Fprintf(out, "Reusing uploaded image %s for %s\n", id, d.name)
+					d.imageID = id
+					continue
+				}
+				_ = api.DeleteImage(imageURL, tok.Value, id)
+			}
+
+			imageName := fmt.Sprintf("Migrated-%s", spec.Name)
+			if i > 0 {
+				imageName = fmt.Sprintf("Migrated-%s-disk%d", spec.Name, i)
+			}
+
+			if spec.Sparse && d.format == "raw" {
+				if err := sparsifyDisk(d, out); err != nil {
+					return result, err
+				}
+			}
+
+			fmt.Fprintf(out, "Creating temporary image %s for VM '%s'...\n", imageName, spec.Name)
+			fmt.Fprintf(out, "Starting upload of %s as %s (%d MB)\n", d.name, d.format, d.size/1024/1024)
+
+			imgReq := api.CreateImageRequest{
+				Name:         imageName,
+				ContainerFmt: "bare",
+				DiskFmt:      d.format,
+				Visibility:   "shared",
+			}
+
+			opts := spec.Upload
+			opts.Size = d.size
+			opts.Output = out
+
+			d.imageID, err = api.CreateAndUploadImageWithOptions(imageURL, tok.Value, imgReq, d.data, opts)
+			if err != nil {
+				return result, fmt.Errorf("failed to create/upload image: %v", err)
+			}
+
+			fmt.Fprintf(out, "\nImage created: %s\n", d.imageID)
+			state.Disks[i].ImageID = d.imageID
+			state.Disks[i].ImageDeleted = false
+			if err := state.save(); err != nil {
+				return result, err
+			}
+		}
+		if err := state.reached(stageImageUploaded); err != nil {
+			return result, err
+		}
 
 		vmReq := api.CreateVMRequest{}
-		vmReq.Server.Name = migrateFlagVMName
+		vmReq.Server.Name = spec.Name
 		vmReq.Server.FlavorRef = flavorRef
-		vmReq.Server.ImageRef = imageID
+		vmReq.Server.ImageRef = disks[0].imageID
 		vmReq.Server.Networks = "none"
 
-		// Force SATA block device
-		// NOTE: This is a bit of a hack to force the use of SATA for the root volume
-		// so udev in the VM uses /dev/sda instead of /dev/vda, as with VMWare.
-		mapping := map[string]interface{}{
-			"boot_index":            0,
-			"uuid":                  imageID,
-			"source_type":           "image",
-			"destination_type":      "volume",
-			"volume_size":           imageSizeGB,
-			"delete_on_termination": true,
-			"disk_bus":              migrateFlagDiskBus,
-			"volume_type":           "nvme_ec7_2",
-		}
-		vmReq.Server.BlockDeviceMappingV2 = []map[string]interface{}{mapping}
-
-		fmt.Printf("Creating VM '%s'...\n", migrateFlagVMName)
+		// Set the disk bus explicitly (scsi by default) so udev in the VM
+		// names disks /dev/sdX as with VMWare, instead of /dev/vdX
+		for i, d := range disks {
+			bootIndex := -1
+			if i == 0 {
+				bootIndex = 0
+			}
+			mapping := map[string]interface{}{
+				"boot_index":            bootIndex,
+				"uuid":                  d.imageID,
+				"source_type":           "image",
+				"destination_type":      "volume",
+				"volume_size":           d.sizeGB,
+				"delete_on_termination": true,
+				"disk_bus":              d.bus,
+			}
+			if volumeType != "" {
+				mapping["volume_type"] = volumeType
+			}
+			vmReq.Server.BlockDeviceMappingV2 = append(vmReq.Server.BlockDeviceMappingV2, mapping)
+		}
+
+		fmt.Fprintf(out, "Creating VM '%s'...\n", spec.Name)
 		vmResp, err := api.CreateVM(computeURL, tok.Value, vmReq)
 		if err != nil {
-			return fmt.Errorf("failed to create VM: %v", err)
+			return result, fmt.Errorf("failed to create VM: %v", err)
 		}
 
-		// Wait for ACTIVE
-		vmDetails, err := api.WaitForStatus(computeURL, tok.Value, vmResp.Server.ID, "ACTIVE")
-		if err != nil {
-			return fmt.Errorf("failed waiting for VM to become ACTIVE: %v", err)
+		state.VMID = vmResp.Server.ID
+		if err := state.reached(stageVMCreated); err != nil {
+			return result, err
 		}
+	}
 
-		netInfo := make([]map[string]interface{}, 0)
-		for i, netNameOrID := range networkIDs {
-			netNameOrID = strings.TrimSpace(netNameOrID)
-			macAddr := strings.TrimSpace(macAddresses[i])
+	result.VMID = state.VMID
 
-			// If the user specified "auto", then omit the mac_addr field by setting it to empty.
-			if strings.ToLower(macAddr) == "auto" {
-				macAddr = ""
-			}
+	// Wait for ACTIVE
+	vmDetails, err := api.WaitForStatus(computeURL, tok.Value, state.VMID, "ACTIVE")
+	if err != nil {
+		return result, fmt.Errorf("failed waiting for VM to become ACTIVE: %v", err)
+	}
 
-			// Try to resolve network name->ID
-			netID, err := api.GetNetworkIDByName(networkURL, tok.Value, netNameOrID)
-			if err == nil && netID != "" {
-				netNameOrID = netID
-			}
+	for i, netNameOrID := range spec.Networks {
+		netNameOrID = strings.TrimSpace(netNameOrID)
+		macAddr := strings.TrimSpace(spec.MACs[i])
 
-			fmt.Printf("Attaching network '%s' to VM '%s' with MAC '%s'...\n",
+		// If the user specified "auto", then omit the mac_addr field by setting it to empty.
+		if strings.ToLower(macAddr) == "auto" {
+			macAddr = ""
+		}
+
+		// Try to resolve network name->ID
+		netID, err := api.GetNetworkIDByName(networkURL, tok.Value, netNameOrID)
+		if err == nil && netID != "" {
+			netNameOrID = netID
+		}
+
+		// Reuse the port of a previous run, it may hold the MAC already
+		if i < len(state.Ports) {
+			if _, err := api.GetPortDetails(networkURL, tok.Value, state.Ports[i].PortID); err != nil {
+				state.Ports = state.Ports[:i]
+			}
+		}
+		if i >= len(state.Ports) {
+			fmt.Fprintf(out, "Attaching network '%s' to VM '%s' with MAC '%s'...\n",
 				netNameOrID, vmDetails.ID, macAddr)
 
 			// Create a port, using the MAC address for unmanaged networks
 			portResp, err := api.CreatePort(networkURL, tok.Value, netNameOrID, macAddr)
 			if err != nil {
-				return fmt.Errorf("failed to create port on network %s: %v", netNameOrID, err)
+				return result, fmt.Errorf("failed to create port on network %s: %v", netNameOrID, err)
+			}
+			state.Ports = append(state.Ports, migrationStatePort{
+				NetworkID:  netNameOrID,
+				PortID:     portResp.Port.ID,
+				MACAddress: portResp.Port.MACAddress,
+			})
+			if err := state.save(); err != nil {
+				return result, err
 			}
+		}
 
-			// Attach the port to the VM (unchanged)
-			_, err = api.AttachNetworkToVM(networkURL, computeURL, tok.Value, vmDetails.ID, "", portResp.Port.ID, nil)
+		port := &state.Ports[i]
+		if !port.Attached {
+			_, err = api.AttachNetworkToVM(networkURL, computeURL, tok.Value, vmDetails.ID, "", port.PortID, nil)
 			if err != nil {
-				return fmt.Errorf("failed to attach port '%s' to VM '%s': %v", portResp.Port.ID, vmDetails.ID, err)
+				return result, fmt.Errorf("failed to attach port '%s' to VM '%s': %v", port.PortID, vmDetails.ID, err)
+			}
+			port.Attached = true
+			if err := state.save(); err != nil {
+				return result, err
 			}
-
-			// Optionally, add the network info to your summary.
-			netInfo = append(netInfo, map[string]interface{}{
-				"network_id":  netNameOrID,
-				"mac_address": portResp.Port.MACAddress,
-			})
 		}
 
-		// -- Not very reliable if the VM is hung since
-		// this only sends a soft os-stop signal, it takes
-		// ~5 minutes if acpid is not running in the VM.
-		if migrateFlagShutdown {
-			fmt.Printf("Shutting down VM '%s'...\n", vmDetails.ID)
-			if err := api.StopVM(computeURL, tok.Value, vmDetails.ID); err != nil {
-				return fmt.Errorf("failed to shut down VM: %v", err)
-			}
+		result.Networks = append(result.Networks, migrationPort{
+			NetworkID:  port.NetworkID,
+			MACAddress: port.MACAddress,
+		})
+	}
+	if err := state.reached(stagePortsAttached); err != nil {
+		return result, err
+	}
+
+	// -- Not very reliable if the VM is hung since
+	// this only sends a soft os-stop signal, it takes
+	// ~5 minutes if acpid is not running in the VM.
+	if spec.Shutdown && !state.Stopped {
+		fmt.Fprintf(out, "Shutting down VM '%s'...\n", vmDetails.ID)
+		if err := api.StopVM(computeURL, tok.Value, vmDetails.ID); err != nil {
+			return result, fmt.Errorf("failed to shut down VM: %v", err)
 		}
+		state.Stopped = true
+	}
 
-		fmt.Printf("Deleting temporary image %s...\n", imageID)
-		err = api.DeleteImage(imageURL, tok.Value, imageID)
-		if err != nil {
-			return fmt.Errorf("failed to delete temporary image: %v", err)
+	for i := range state.Disks {
+		disk := &state.Disks[i]
+		if disk.ImageID == "" || disk.ImageDeleted {
+			continue
 		}
+		fmt.Fprintf(out, "Deleting temporary image %s...\n", disk.ImageID)
+		if err := api.DeleteImage(imageURL, tok.Value, disk.ImageID); err != nil {
+			return result, fmt.Errorf("failed to delete temporary image: %v", err)
+		}
+		disk.ImageDeleted = true
+	}
+	if err := state.reached(stageImageDeleted); err != nil {
+		return result, err
+	}
+
+	result.VMName = vmDetails.Name
+	result.PowerState = fmt.Sprintf("%d (%s)",
+		vmDetails.PowerState,
+		getPowerStateString(vmDetails.PowerState))
+	return result, nil
+}
 
-		summary := map[string]interface{}{
-			"vm_id":   vmDetails.ID,
-			"vm_name": vmDetails.Name,
-			"power_state": fmt.Sprintf("%d (%s)",
-				vmDetails.PowerState,
-				getPowerStateString(vmDetails.PowerState)),
-			"networks": netInfo,
+// openMigrationDisk() resolves a source disk to its descriptor and opens
+// its data. The bus is busOverride if set, then the VMX controller type,
+// then ddb.adapterType, then 'disk_bus' from config, then scsi.
+func openMigrationDisk(src vmconfig.Disk, busOverride string, out io.Writer) (*migrationDisk, error) {
+	desc, err := loadMigrationVMDK(src.Path, out)
+	if err != nil {
+		return nil, err
+	}
+	if children, err := desc.Children(); err == nil && len(children) > 0 {
+		return nil, fmt.Errorf("%s has unconsolidated snapshot deltas (%s); consolidate the snapshots in vSphere before migrating",
+			src.Path, strings.Join(children, ", "))
+	}
+
+	bus := busOverride
+	for _, candidate := range []string{src.Bus, desc.DiskBus(), viper.GetString("disk_bus"), "scsi"} {
+		if bus == "" {
+			bus = candidate
 		}
+	}
+	if err := validateDiskBus(bus); err != nil {
+		return nil, err
+	}
+
+	data, err := desc.OpenVMDK()
+	if err != nil {
+		return nil, err
+	}
+
+	d := &migrationDisk{
+		desc:   desc,
+		name:   desc.Path,
+		data:   data,
+		format: data.Format,
+		size:   data.Size,
+		bus:    bus,
+		sizeGB: roundUpGB(desc.Capacity()),
+	}
+
+	fmt.Fprintf(out, "Disk %s: %s, %d extent(s), capacity %d GB", filepath.Base(desc.Path), stringOrNone(desc.CreateType), len(desc.Extents), d.sizeGB)
+	if desc.Cylinders > 0 {
+		fmt.Fprintf(out, ", geometry %d/%d/%d", desc.Cylinders, desc.Heads, desc.SectorsPerTrack)
+	}
+	if desc.AdapterType != "" {
+		fmt.Fprintf(out, ", adapter %s", desc.AdapterType)
+	}
+	fmt.Fprintf(out, ", bus %s\n", bus)
+	return d, nil
+}
 
-		data, _ := json.MarshalIndent(summary, "", "  ")
-		fmt.Println(string(data))
+// openSpecDisk() opens a disk of spec: from its OVA if it has one, as a
+// VMDK, or as a plain image file or block device (qcow2, raw)
+func openSpecDisk(spec migrationSpec, src vmconfig.Disk, out io.Writer) (*migrationDisk, error) {
+	switch {
+	case spec.OVA != "":
+		return openOVADisk(spec.OVA, src, spec.DiskBus, out)
+	case strings.HasSuffix(strings.ToLower(src.Path), ".vmdk"):
+		return openMigrationDisk(src, spec.DiskBus, out)
+	}
+	return openImageDisk(src, spec.DiskBus, out)
+}
 
-		return nil
-	},
+// openImageDisk() opens a KVM disk image or block device for upload as
+// is. QCOW2 overlays are refused, their backing file would be missing.
+// The bus is busOverride if set, then the source bus, then 'disk_bus'
+// from config, then virtio.
+func openImageDisk(src vmconfig.Disk, busOverride string, out io.Writer) (*migrationDisk, error) {
+	f, err := os.Open(src.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk: %v", err)
+	}
+
+	// block devices report no size in stat, seeking to the end works for both
+	size, err := f.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to get size of %s: %v", src.Path, err)
+	}
+
+	info, err := diskimage.Detect(f, size)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %v", src.Path, err)
+	}
+	if info.Backing {
+		f.Close()
+		return nil, fmt.Errorf("%s is a qcow2 overlay with a backing file; flatten it first (virsh blockpull or qemu-img convert)", src.Path)
+	}
+
+	bus := busOverride
+	for _, candidate := range []string{src.Bus, viper.GetString("disk_bus"), "virtio"} {
+		if bus == "" && validateDiskBus(candidate) == nil {
+			bus = candidate
+		}
+	}
+	if err := validateDiskBus(bus); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	d := &migrationDisk{
+		name:   src.Path,
+		data:   f,
+		format: info.Format,
+		size:   size,
+		bus:    bus,
+		sizeGB: roundUpGB(info.VirtualSize),
+	}
+	fmt.Fprintf(out, "Disk %s: %s (%s), capacity %d GB, bus %s\n", filepath.Base(src.Path), info.Format, stringOrNone(info.Detail), d.sizeGB, bus)
+	return d, nil
 }
 
-// migrateFindCmd is the 'migrate find' subcommand
-var migrateFindCmd = &cobra.Command{
-	Use:   "find <pattern>",
-	Short: "Find a VMDK file matching the pattern in /mnt/vmdk",
-	Args:  cobra.ExactArgs(1),
-	RunE: func(cmd *cobra.Command, args []string) error {
-		pattern := args[0]
+// qcow2DiskData is a raw disk converted to qcow2 as it's uploaded
+type qcow2DiskData struct {
+	*diskimage.QCOW2Stream
+	io.Closer // the raw disk
+}
 
-		fmt.Printf("Searching for VMDK files matching '%s' in /mnt/vmdk...\n", pattern)
-		start := time.Now()
+// sparsifyDisk() replaces the raw data of d with a qcow2 conversion that
+// leaves out holes (SEEK_DATA/SEEK_HOLE) and all-zero clusters. Finding
+// them reads the data of the disk once before the upload.
+func sparsifyDisk(d *migrationDisk, out io.Writer) error {
+	r, ok := d.data.(io.ReaderAt)
+	if !ok {
+		return nil
+	}
+
+	fmt.Fprintf(out, "Scanning %s for data...\n", d.name)
+	stream, err := diskimage.NewQCOW2Stream(diskimage.AsRawDisk(r, d.size), d.size)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s to qcow2: %v", d.name, err)
+	}
+	fmt.Fprintf(out, "Disk %s holds %s of data in %s, uploading as qcow2 (%s)\n",
+		d.name, formatByteSize(stream.DataSize), formatByteSize(d.size), formatByteSize(stream.Size))
+
+	d.data = &qcow2DiskData{QCOW2Stream: stream, Closer: d.data}
+	d.format = "qcow2"
+	d.size = stream.Size
+	return nil
+}
 
-		matches, err := findVMDKsParallel(pattern)
-		duration := time.Since(start)
+// ovaDiskData is a disk streamed out of an OVA, closing the archive with it
+type ovaDiskData struct {
+	*io.SectionReader
+	ova *vmconfig.OVA
+}
 
-		fmt.Printf("\nSearch completed in %s\n", duration)
+func (d *ovaDiskData) Close() error {
+	return d.ova.Close()
+}
 
-		if err != nil {
-			return err
+// openOVADisk() opens a disk inside an OVA for upload in place. The
+// format and capacity come from the disk's header, so streamOptimized
+// VMDKs go to Glance as vmdk.
+func openOVADisk(ovaPath string, src vmconfig.Disk, busOverride string, out io.Writer) (*migrationDisk, error) {
+	ova, err := vmconfig.OpenOVA(ovaPath)
+	if err != nil {
+		return nil, err
+	}
+	r, size, err := ova.Open(src.Path)
+	if err != nil {
+		ova.Close()
+		return nil, err
+	}
+
+	info, err := diskimage.Detect(r, size)
+	if err != nil {
+		ova.Close()
+		return nil, fmt.Errorf("%s: %v", src.Path, err)
+	}
+
+	bus := busOverride
+	for _, candidate := range []string{src.Bus, viper.GetString("disk_bus"), "scsi"} {
+		if bus == "" {
+			bus = candidate
 		}
+	}
+	if err := validateDiskBus(bus); err != nil {
+		ova.Close()
+		return nil, err
+	}
+
+	d := &migrationDisk{
+		name:   fmt.Sprintf("%s:%s", filepath.Base(ovaPath), src.Path),
+		data:   &ovaDiskData{SectionReader: r, ova: ova},
+		format: info.Format,
+		size:   size,
+		bus:    bus,
+		sizeGB: roundUpGB(info.VirtualSize),
+	}
+	fmt.Fprintf(out, "Disk %s: %s %s, capacity %d GB, bus %s\n", d.name, info.Format, stringOrNone(info.Detail), d.sizeGB, bus)
+	return d, nil
+}
 
-		if len(matches) == 0 {
-			fmt.Println("No matching VMDK files found.")
-			return nil
-		}
+// roundUpGB() converts bytes to whole GB, rounding up
+func roundUpGB(bytes int64) int64 {
+	return (bytes + 1024*1024*1024 - 1) / (1024 * 1024 * 1024)
+}
 
-		fmt.Println("\nMatching VMDK files:")
-		for _, match := range matches {
-			fmt.Println(match)
+// loadMigrationVMDK() parses the descriptor for path. A -flat.vmdk is read
+// through the descriptor next to it; without one it is taken as a single
+// raw extent.
+func loadMigrationVMDK(path string, out io.Writer) (*diskimage.VMDKDescriptor, error) {
+	if strings.HasSuffix(path, "-flat.vmdk") {
+		descPath := strings.TrimSuffix(path, "-flat.vmdk") + ".vmdk"
+		if diskimage.IsVMDKDescriptor(descPath) {
+			fmt.Fprintf(out, "Using descriptor %s\n", descPath)
+			path = descPath
 		}
-
-		return nil
-	},
+	}
+
+	desc, err := diskimage.ParseVMDK(path)
+	if err == nil {
+		return desc, nil
+	}
+	if !strings.HasSuffix(path, "-flat.vmdk") {
+		return nil, fmt.Errorf("failed to read VMDK: %v", err)
+	}
+
+	info, statErr := os.Stat(path)
+	if statErr != nil {
+		return nil, fmt.Errorf("failed to stat file: %v", statErr)
+	}
+	fmt.Fprintf(out, "No descriptor found for %s, uploading it as a raw extent\n", path)
+	return &diskimage.VMDKDescriptor{
+		Path: path,
+		Extents: []diskimage.VMDKExtent{
+			{Access: "RW", Sectors: info.Size() / 512, Type: "FLAT", Path: path},
+		},
+	}, nil
 }
 
 // Flags for migrate vm
 var (
 	migrateFlagVMName     string
 	migrateFlagVMDKPath   string
+	migrateFlagVMX        string
+	migrateFlagOVA        string
+	migrateFlagLibvirtXML string
+	migrateFlagNetworkMap string
 	migrateFlagFlavorRef  string
 	migrateFlagNetworkCSV string
 	migrateFlagMacAddrCSV string
 	migrateFlagVMSize     int64
 	migrateFlagDiskBus    string
+	migrateFlagVolumeType string
 	migrateFlagShutdown   bool
+	migrateFlagResume     bool
+	migrateFlagBwLimit    string
+	migrateFlagRetries    int
+	migrateFlagStage      bool
+	migrateFlagPreflight  bool
+	migrateFlagSparse     bool
+	migrateFlagCPUs       int
+	migrateFlagRAM        string
+	migrateFlagExact      bool
+	migrateFlagPrefer     string
+
+	migrateFlagSourceVM      string
+	migrateFlagSourceTimeout time.Duration
 )
 
 func init() {
-	migrateVMCmd.Flags().StringVar(&migrateFlagVMName, "name", "", "Name of the VM")
-	migrateVMCmd.Flags().StringVar(&migrateFlagVMDKPath, "vmdk", "", "Local path to VMDK file")
-	migrateVMCmd.Flags().StringVar(&migrateFlagFlavorRef, "flavor", "", "Flavor name or ID")
-	migrateVMCmd.Flags().StringVar(&migrateFlagNetworkCSV, "networks", "", "Comma-separated network names/IDs")
-	migrateVMCmd.Flags().StringVar(&migrateFlagMacAddrCSV, "mac", "", "Comma-separated MAC addresses (one per network)")
-	migrateVMCmd.Flags().Int64Var(&migrateFlagVMSize, "size", 0, "Optional: size in GB if extending the image")
-	migrateVMCmd.Flags().StringVar(&migrateFlagDiskBus, "disk-bus", "scsi", "Disk bus for the root volume, default: scsi")
-	migrateVMCmd.Flags().BoolVar(&migrateFlagShutdown, "shutdown", false, "Shut down the new VM after creation")
+	addMigrateVMFlags(migrateVMCmd)
+	migrateVMCmd.Flags().BoolVar(&migrateFlagPreflight, "preflight", false, "Only run the pre-flight checks of 'migrate check'")
+	addMigrateVMFlags(migrateCheckCmd)
 
 	migrateCmd.AddCommand(migrateVMCmd)
-	migrateCmd.AddCommand(migrateFindCmd)
+	migrateCmd.AddCommand(migrateCheckCmd)
 
 	rootCmd.AddCommand(migrateCmd)
 }
+
+// addMigrateVMFlags() registers the flags describing a migration, shared
+// by 'migrate vm' and 'migrate check'
+func addMigrateVMFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&migrateFlagVMName, "name", "", "Name of the VM")
+	cmd.Flags().StringVar(&migrateFlagVMDKPath, "vmdk", "", "Local path to the VMDK descriptor (or -flat.vmdk)")
+	cmd.Flags().StringVar(&migrateFlagVMX, "vmx", "", "Local path to the VM's .vmx file; migrates all of its disks and NICs")
+	cmd.Flags().StringVar(&migrateFlagOVA, "ova", "", "Local path to an OVA appliance; migrates the disks and NICs of its OVF, streaming the disks out of the archive")
+	cmd.Flags().StringVar(&migrateFlagLibvirtXML, "libvirt-xml", "", "Local path to a KVM guest's libvirt domain XML (virsh dumpxml); migrates its qcow2/raw disks and NICs")
+	cmd.Flags().StringVar(&migrateFlagNetworkMap, "network-map", "", "YAML file mapping vSphere portgroups, OVF networks or libvirt bridges to VHI networks")
+	cmd.Flags().StringVar(&migrateFlagFlavorRef, "flavor", "", "Flavor name or ID (default with --vmx: closest match to the VM's vCPUs and memory, or --cpus/--ram)")
+	cmd.Flags().StringVar(&migrateFlagNetworkCSV, "networks", "", "Comma-separated network names/IDs")
+	cmd.Flags().StringVar(&migrateFlagMacAddrCSV, "mac", "", "Comma-separated MAC addresses (one per network)")
+	cmd.Flags().Int64Var(&migrateFlagVMSize, "size", 0, "Optional: root volume size in GB (default: the disk's capacity)")
+	cmd.Flags().StringVar(&migrateFlagDiskBus, "disk-bus", "", "Disk bus for the root volume (default: from ddb.adapterType, then 'disk_bus' from config, then scsi)")
+	cmd.Flags().StringVar(&migrateFlagVolumeType, "volume-type", "", "Volume type for the root volume (default: 'volume_type' from config)")
+	cmd.Flags().BoolVar(&migrateFlagShutdown, "shutdown", false, "Shut down the new VM after creation")
+	cmd.Flags().BoolVar(&migrateFlagResume, "resume", false, "Continue a failed migration of the same VM from its last completed stage")
+	cmd.Flags().StringVar(&migrateFlagBwLimit, "bwlimit", "", "Limit upload bandwidth in bytes/s, e.g. 50M (default: unlimited)")
+	cmd.Flags().IntVar(&migrateFlagRetries, "retries", 3, "Retry a failed upload this many times; each retry resends the file from the start")
+	cmd.Flags().BoolVar(&migrateFlagStage, "stage", false, "Upload via Glance stage + glance-direct import instead of a direct PUT")
+	cmd.Flags().BoolVar(&migrateFlagSparse, "sparse", false, "Convert raw and flat disks to qcow2 while uploading, leaving out holes and zeroed blocks")
+	cmd.Flags().StringVar(&migrateFlagSourceVM, "source-vm", "", "vSphere VM to power off before the upload (needs vcenter_* in config)")
+	cmd.Flags().DurationVar(&migrateFlagSourceTimeout, "source-timeout", defaultSourceTimeout, "How long to wait for a graceful shutdown of --source-vm before powering it off")
+
+	addFlavorMatchFlags(cmd, &migrateFlagCPUs, &migrateFlagRAM, &migrateFlagExact, &migrateFlagPrefer)
+	cmd.MarkFlagsMutuallyExclusive("vmdk", "vmx", "ova", "libvirt-xml")
+	cmd.MarkFlagsMutuallyExclusive("flavor", "cpus")
+	cmd.MarkFlagsMutuallyExclusive("flavor", "ram")
+}
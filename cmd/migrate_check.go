@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// migrateCheckCmd is the 'migrate check' subcommand
+var migrateCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Run the pre-flight checks of a migration without migrating",
+	Long: `Takes the same flags as 'migrate vm' and checks, without changing
+anything, that:
+  - no VM with the same name exists
+  - the flavor exists and every network resolves
+  - no port already uses one of the requested MAC addresses
+  - every VMDK is readable and not locked by a running VM (.lck files)
+  - the --source-vm is found in vCenter
+  - the volume quota has room for the disks, rounded up to whole GB
+
+Example:
+  vhicmd migrate check --vmx /mnt/vmdk/ds1/myvm/myvm.vmx --network-map portgroups.yaml`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := migrationSpecFromFlags()
+		if err != nil {
+			return err
+		}
+		return runPreflight(spec, os.Stdout)
+	},
+}
+
+// preflight collects the outcome of pre-flight checks
+type preflight struct {
+	out    io.Writer
+	failed int
+}
+
+// report() prints the outcome of one check
+func (p *preflight) report(check string, err error) {
+	if err != nil {
+		p.failed++
+		fmt.Fprintf(p.out, "[FAIL] %s: %v\n", check, err)
+		return
+	}
+	fmt.Fprintf(p.out, "[ OK ] %s\n", check)
+}
+
+// reportQuota() is report for a quota check, where a quota that can't be
+// read is a warning rather than a failure
+func (p *preflight) reportQuota(check string, err error) {
+	var short quotaShortage
+	if err != nil && !errors.As(err, &short) {
+		fmt.Fprintf(p.out, "[WARN] %s: couldn't read the quota: %v\n", check, err)
+		return
+	}
+	p.report(check, err)
+}
+
+// runPreflight() checks that spec can be migrated, printing one line per
+// check. Returns an error if any check failed.
+func runPreflight(spec migrationSpec, out io.Writer) error {
+	computeURL, err := validateTokenEndpoint(tok, "compute")
+	if err != nil {
+		return err
+	}
+	networkURL, err := validateTokenEndpoint(tok, "network")
+	if err != nil {
+		return err
+	}
+	storageURL, err := validateTokenEndpoint(tok, "volumev3")
+	if err != nil {
+		return err
+	}
+
+	// a resumed migration may already hold the VM and the ports
+	state, err := loadMigrationState(spec.Name)
+	if err != nil {
+		return err
+	}
+	if state == nil || !spec.Resume {
+		state = &migrationState{}
+	}
+
+	p := &preflight{out: out}
+	fmt.Fprintf(out, "Pre-flight checks for %s:\n", spec.Name)
+
+	p.report(fmt.Sprintf("no VM named %s", spec.Name), checkVMNameFree(computeURL, spec.Name, state.VMID))
+	p.report(fmt.Sprintf("flavor %s exists", spec.Flavor), checkFlavorExists(computeURL, spec.Flavor))
+
+	for _, network := range spec.Networks {
+		network = strings.TrimSpace(network)
+		p.report(fmt.Sprintf("network %s resolves", network), checkNetworkResolves(networkURL, network))
+	}
+
+	if len(spec.Networks) != len(spec.MACs) {
+		p.report("MAC addresses", fmt.Errorf("%d network(s) but %d MAC address(es)", len(spec.Networks), len(spec.MACs)))
+	}
+	held := make(map[string]bool)
+	for _, port := range state.Ports {
+		held[port.PortID] = true
+	}
+	for _, mac := range spec.MACs {
+		mac = strings.ToLower(strings.TrimSpace(mac))
+		if mac == "auto" {
+			continue
+		}
+		p.report(fmt.Sprintf("MAC %s is free", mac), checkMACFree(networkURL, mac, held))
+	}
+
+	if spec.SourceVM != "" {
+		p.report(fmt.Sprintf("vSphere VM %s found", spec.SourceVM), checkSourceVM(spec.SourceVM))
+	}
+
+	var totalGB int64
+	for i, src := range spec.Disks {
+		d, err := openSpecDisk(spec, src, io.Discard)
+		if err == nil {
+			err = checkDiskReadable(d)
+			d.data.Close()
+		}
+		p.report(fmt.Sprintf("disk %s is readable", src.Path), err)
+		if err != nil {
+			continue
+		}
+
+		// with --source-vm the VM is stopped and the locks waited out
+		if spec.SourceVM == "" && d.desc != nil {
+			var lockErr error
+			if locks := d.desc.Locks(); len(locks) > 0 {
+				lockErr = fmt.Errorf("locked by %s; is the VM still powered on?", strings.Join(locks, ", "))
+			}
+			p.report(fmt.Sprintf("disk %s is not locked", src.Path), lockErr)
+		}
+
+		if i == 0 && spec.Size > d.sizeGB {
+			d.sizeGB = spec.Size
+		}
+		totalGB += d.sizeGB
+	}
+
+	volumeType := spec.VolumeType
+	if volumeType == "" {
+		volumeType = viper.GetString("volume_type")
+	}
+	p.reportQuota(fmt.Sprintf("volume quota for %d GB in %d volume(s)", totalGB, len(spec.Disks)),
+		checkVolumeQuota(storageURL, volumeType, totalGB, len(spec.Disks)))
+	if state.VMID == "" {
+		p.reportQuota(fmt.Sprintf("compute quota for flavor %s", spec.Flavor), checkComputeQuota(computeURL, resolveFlavorID(computeURL, spec.Flavor), 1))
+	}
+	if ports := len(spec.Networks) - len(state.Ports); ports > 0 {
+		p.reportQuota(fmt.Sprintf("port quota for %d port(s)", ports), checkPortQuota(networkURL, ports))
+	}
+
+	if p.failed > 0 {
+		return fmt.Errorf("%d pre-flight check(s) failed", p.failed)
+	}
+	fmt.Fprintln(out, "All pre-flight checks passed")
+	return nil
+}
+
+// checkVMNameFree() fails if a VM named name exists, other than the one
+// a resumed migration created (ownID)
+func checkVMNameFree(computeURL, name, ownID string) error {
+	// Nova matches names as a regex
+	filter := url.QueryEscape("^" + regexp.QuoteMeta(name) + "$")
+	vms, err := api.ListVMs(computeURL, tok.Value, map[string]string{"name": filter})
+	if err != nil {
+		return err
+	}
+	for _, vm := range vms.Servers {
+		if vm.Name == name && vm.ID != ownID {
+			return fmt.Errorf("VM %s already has that name", vm.ID)
+		}
+	}
+	return nil
+}
+
+// checkSourceVM() looks up the vSphere VM to power off in vCenter
+func checkSourceVM(name string) error {
+	vc, err := vcenterLogin()
+	if err != nil {
+		return err
+	}
+	defer vc.Logout()
+
+	_, err = vc.FindVM(name)
+	return err
+}
+
+// checkFlavorExists() resolves a flavor name or ID
+func checkFlavorExists(computeURL, flavor string) error {
+	if _, err := api.GetFlavorIDByName(computeURL, tok.Value, flavor); err == nil {
+		return nil
+	}
+	if _, err := api.GetFlavorDetails(computeURL, tok.Value, flavor); err != nil {
+		return fmt.Errorf("no flavor with that name or ID")
+	}
+	return nil
+}
+
+// checkNetworkResolves() resolves a network name or ID
+func checkNetworkResolves(networkURL, network string) error {
+	_, nameErr := api.GetNetworkIDByName(networkURL, tok.Value, network)
+	if nameErr == nil {
+		return nil
+	}
+	networks, err := api.ListNetworks(networkURL, tok.Value, map[string]string{"id": network})
+	if err != nil {
+		return err
+	}
+	if len(networks.Networks) == 0 {
+		return nameErr
+	}
+	return nil
+}
+
+// checkMACFree() fails if a port uses mac, unless it's one of the held
+// ports of a resumed migration
+func checkMACFree(networkURL, mac string, held map[string]bool) error {
+	if err := validateMacAddr(mac); err != nil {
+		return err
+	}
+	ports, err := api.ListPorts(networkURL, tok.Value, map[string]string{"mac_address": url.QueryEscape(mac)})
+	if err != nil {
+		return err
+	}
+	for _, port := range ports.Ports {
+		if held[port.ID] {
+			continue
+		}
+		owner := "no device"
+		if port.DeviceID != "" {
+			owner = fmt.Sprintf("%s %s", stringOrNone(port.DeviceOwner), port.DeviceID)
+		}
+		return fmt.Errorf("used by port %s on network %s (%s)", port.ID, port.NetworkID, owner)
+	}
+	return nil
+}
+
+// checkDiskReadable() reads the first and the last sector of a disk, so
+// missing or truncated extents show up before the upload
+func checkDiskReadable(d *migrationDisk) error {
+	buf := make([]byte, 512)
+	if _, err := io.ReadFull(d.data, buf); err != nil {
+		return fmt.Errorf("failed to read the first sector: %v", err)
+	}
+	if d.size > int64(len(buf)) {
+		if _, err := d.data.Seek(d.size-int64(len(buf)), io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(d.data, buf); err != nil {
+			return fmt.Errorf("failed to read the last sector: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/facette/natsort"
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/diskimage"
+	"github.com/jessegalley/vhicmd/internal/responseparser"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// defaultVMDKRoot is searched when 'vmdk_roots' isn't configured
+const defaultVMDKRoot = "/mnt/vmdk"
+
+// vmdkIndex is the on-disk index of the VMDK descriptors under the
+// search roots, so 'migrate find' doesn't rescan the datastores each time
+type vmdkIndex struct {
+	Roots   []string   `json:"roots"`
+	Updated time.Time  `json:"updated"`
+	Disks   []vmdkFile `json:"disks"`
+}
+
+// vmdkFile is one indexed disk, also printed by 'migrate find --json'
+type vmdkFile struct {
+	Path      string    `json:"path"`
+	VMX       string    `json:"vmx,omitempty"` // the .vmx next to the disk, if there is exactly one
+	Datastore string    `json:"datastore"`
+	Size      int64     `json:"size"`     // capacity in bytes
+	Modified  time.Time `json:"modified"` // newest of the descriptor and its extents
+	Locked    bool      `json:"locked"`   // checked when searching, not indexed
+}
+
+// migrateFindCmd is the 'migrate find' subcommand
+var migrateFindCmd = &cobra.Command{
+	Use:   "find [pattern]",
+	Short: "Find VMDK files by name in the configured datastores",
+	Long: `Search the VMDK descriptors under the 'vmdk_roots' from config
+(comma-separated, default /mnt/vmdk); every directory in a root is a
+datastore. The datastores are scanned once into an index, which is reused
+until --reindex is given or the roots change.
+
+The pattern is a case-insensitive substring of the disk name, a glob
+on the file name if it contains *, ? or [, or with --regex a regular
+expression on the full path.
+
+Example:
+  vhicmd config set vmdk_roots /mnt/vmdk,/mnt/nfs-vmdk
+  vhicmd migrate find --reindex
+  vhicmd migrate find 'web*'
+  vhicmd migrate find --regex '/ds[12]/db' --json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && !migrateFindFlagReindex {
+			return fmt.Errorf("must provide a pattern, or --reindex to only rebuild the index")
+		}
+
+		// keep stdout clean for --json
+		status := io.Writer(os.Stdout)
+		if flagJsonOutput {
+			status = os.Stderr
+		}
+
+		index, err := loadVMDKIndex(vmdkRoots(), migrateFindFlagReindex, status)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return nil
+		}
+
+		match, err := vmdkMatcher(args[0], migrateFindFlagRegex)
+		if err != nil {
+			return err
+		}
+
+		matches := []vmdkFile{}
+		for _, disk := range index.Disks {
+			if !match(disk.Path) {
+				continue
+			}
+			if _, err := os.Stat(disk.Path); err != nil {
+				// removed since the index was built
+				continue
+			}
+			desc, err := loadMigrationVMDK(disk.Path, io.Discard)
+			if err != nil {
+				desc = &diskimage.VMDKDescriptor{Path: disk.Path}
+			}
+			disk.Locked = len(desc.Locks()) > 0
+			matches = append(matches, disk)
+		}
+
+		if flagJsonOutput {
+			data, _ := json.MarshalIndent(matches, "", "  ")
+			fmt.Println(string(data))
+			return nil
+		}
+
+		if len(matches) == 0 {
+			fmt.Println("No matching VMDK files found.")
+		} else {
+			var rows []responseparser.VMDKFile
+			for _, m := range matches {
+				rows = append(rows, responseparser.VMDKFile{
+					Path:      m.Path,
+					Datastore: m.Datastore,
+					Size:      formatByteSize(m.Size),
+					Modified:  m.Modified.Format("2006-01-02 15:04"),
+					Locked:    m.Locked,
+				})
+			}
+			responseparser.PrintVMDKFilesTable(rows)
+		}
+		fmt.Printf("Index of %d disk(s) from %s; refresh with --reindex\n",
+			len(index.Disks), index.Updated.Format("2006-01-02 15:04:05"))
+		return nil
+	},
+}
+
+// vmdkRoots() returns the search roots from 'vmdk_roots' in config, which
+// may be a comma-separated string or a YAML list
+func vmdkRoots() []string {
+	list := viper.GetStringSlice("vmdk_roots")
+	if s, ok := viper.Get("vmdk_roots").(string); ok {
+		list = strings.Split(s, ",")
+	}
+
+	var roots []string
+	for _, root := range list {
+		if root = strings.TrimSpace(root); root != "" {
+			roots = append(roots, filepath.Clean(root))
+		}
+	}
+	if len(roots) == 0 {
+		roots = []string{defaultVMDKRoot}
+	}
+	return roots
+}
+
+// vmdkIndexPath() returns the index file, next to the token file
+func vmdkIndexPath() string {
+	return filepath.Join(filepath.Dir(api.TokenFile), ".vhicmd", "vmdk-index.json")
+}
+
+// loadVMDKIndex() returns the saved index for roots, scanning the
+// datastores first if reindex is set, there is no index yet or it was
+// built for other roots
+func loadVMDKIndex(roots []string, reindex bool, out io.Writer) (vmdkIndex, error) {
+	var index vmdkIndex
+	if !reindex {
+		data, err := os.ReadFile(vmdkIndexPath())
+		if err == nil && json.Unmarshal(data, &index) == nil && slices.Equal(index.Roots, roots) {
+			return index, nil
+		}
+	}
+
+	fmt.Fprintf(out, "Indexing VMDK files in %s...\n", strings.Join(roots, ", "))
+	start := time.Now()
+
+	index = vmdkIndex{Roots: roots, Updated: start}
+	for _, root := range roots {
+		disks, err := scanVMDKRoot(root)
+		if err != nil {
+			return index, err
+		}
+		index.Disks = append(index.Disks, disks...)
+	}
+	sort.Slice(index.Disks, func(i, j int) bool {
+		return natsort.Compare(strings.ToLower(index.Disks[i].Path), strings.ToLower(index.Disks[j].Path))
+	})
+
+	fmt.Fprintf(out, "Indexed %d disk(s) in %s\n", len(index.Disks), time.Since(start).Round(time.Millisecond))
+
+	if err := os.MkdirAll(filepath.Dir(vmdkIndexPath()), 0700); err != nil {
+		return index, fmt.Errorf("failed to create index directory: %v", err)
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return index, err
+	}
+	tmp := vmdkIndexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return index, fmt.Errorf("failed to write VMDK index: %v", err)
+	}
+	return index, os.Rename(tmp, vmdkIndexPath())
+}
+
+// scanVMDKRoot() launches one goroutine per datastore in root
+func scanVMDKRoot(root string) ([]vmdkFile, error) {
+	entries, err := os.ReadDir(root) // Get all top-level directories (datastores)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %v", root, err)
+	}
+
+	var disks []vmdkFile
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		wg.Add(1)
+		go func(datastore string) {
+			defer wg.Done()
+			found := scanVMDKDatastore(filepath.Join(root, datastore), datastore)
+
+			mu.Lock()
+			disks = append(disks, found...)
+			mu.Unlock()
+		}(entry.Name())
+	}
+	wg.Wait()
+
+	return disks, nil
+}
+
+// vmdkExtentSuffix matches VMDK extent and sidecar file names, as opposed
+// to descriptors
+var vmdkExtentSuffix = regexp.MustCompile(`-(flat|delta|sesparse|ctk|rdm|rdmp|[sf]\d{3})\.vmdk$`)
+
+// scanVMDKDatastore() recursively collects the disks of one datastore
+func scanVMDKDatastore(storePath, datastore string) []vmdkFile {
+	var disks []vmdkFile
+	vmxByDir := make(map[string]string)
+
+	filepath.WalkDir(storePath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: Cannot access %s: %v\n", path, err)
+			return nil
+		}
+
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".vmdk") {
+			return nil
+		}
+
+		// List descriptors; extents are reached through them. A flat
+		// extent is only listed when its descriptor is missing.
+		if vmdkExtentSuffix.MatchString(d.Name()) {
+			if !strings.HasSuffix(d.Name(), "-flat.vmdk") {
+				return nil
+			}
+			descPath := strings.TrimSuffix(path, "-flat.vmdk") + ".vmdk"
+			if _, err := os.Stat(descPath); err == nil {
+				return nil
+			}
+		}
+
+		disk := vmdkFile{Path: path, Datastore: datastore}
+		files := []string{path}
+		if desc, err := diskimage.ParseVMDK(path); err == nil {
+			disk.Size = desc.Capacity()
+			for _, e := range desc.Extents {
+				files = append(files, e.Path)
+			}
+		} else if info, err := d.Info(); err == nil {
+			disk.Size = info.Size()
+		}
+		for _, f := range files {
+			if info, err := os.Stat(f); err == nil && info.ModTime().After(disk.Modified) {
+				disk.Modified = info.ModTime()
+			}
+		}
+
+		dir := filepath.Dir(path)
+		vmx, ok := vmxByDir[dir]
+		if !ok {
+			if found, _ := filepath.Glob(filepath.Join(dir, "*.vmx")); len(found) == 1 {
+				vmx = found[0]
+			}
+			vmxByDir[dir] = vmx
+		}
+		disk.VMX = vmx
+
+		disks = append(disks, disk)
+		return nil
+	})
+	return disks
+}
+
+// vmdkMatcher() returns a matcher for pattern: a regular expression on
+// the full path with useRegex, a glob on the file name if pattern has
+// glob characters, otherwise a substring of the disk name. All matching
+// is case-insensitive.
+func vmdkMatcher(pattern string, useRegex bool) (func(path string) bool, error) {
+	if useRegex {
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --regex pattern: %v", err)
+		}
+		return re.MatchString, nil
+	}
+
+	pattern = strings.ToLower(pattern)
+	if strings.ContainsAny(pattern, "*?[") {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %v", pattern, err)
+		}
+		return func(path string) bool {
+			ok, _ := filepath.Match(pattern, strings.ToLower(filepath.Base(path)))
+			return ok
+		}, nil
+	}
+
+	return func(path string) bool {
+		name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".vmdk"), "-flat")
+		return strings.Contains(strings.ToLower(name), pattern)
+	}, nil
+}
+
+var (
+	migrateFindFlagReindex bool
+	migrateFindFlagRegex   bool
+)
+
+func init() {
+	migrateFindCmd.Flags().BoolVar(&migrateFindFlagReindex, "reindex", false, "Rescan the datastores and rebuild the index")
+	migrateFindCmd.Flags().BoolVar(&migrateFindFlagRegex, "regex", false, "Match the pattern as a regular expression on the full path")
+	migrateFindCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+
+	migrateCmd.AddCommand(migrateFindCmd)
+}
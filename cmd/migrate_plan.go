@@ -0,0 +1,442 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/vmconfig"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+// migrationPlan is a batch of VMs to migrate, read from a YAML plan file
+type migrationPlan struct {
+	Parallel   int               `yaml:"parallel"`
+	NetworkMap string            `yaml:"network_map"`
+	Defaults   migrationPlanVM   `yaml:"defaults"`
+	VMs        []migrationPlanVM `yaml:"vms"`
+}
+
+// migrationPlanVM is one VM of a plan; one of vmx, ova, libvirt_xml or
+// vmdks is set
+type migrationPlanVM struct {
+	Name       string   `yaml:"name"`
+	VMX        string   `yaml:"vmx"`
+	OVA        string   `yaml:"ova"`
+	LibvirtXML string   `yaml:"libvirt_xml"`
+	VMDKs      []string `yaml:"vmdks"`
+	Flavor     string   `yaml:"flavor"`
+	Networks   []string `yaml:"networks"`
+	MACs       []string `yaml:"macs"`
+	Size       int64    `yaml:"size"`
+	DiskBus    string   `yaml:"disk_bus"`
+	VolumeType string   `yaml:"volume_type"`
+	Shutdown   bool     `yaml:"shutdown"`
+	Sparse     bool     `yaml:"sparse"`
+	SourceVM   string   `yaml:"source_vm"`
+}
+
+// migrationReport is the outcome of one VM of a plan
+type migrationReport struct {
+	Name     string          `json:"name"`
+	Status   string          `json:"status"`
+	VMID     string          `json:"vm_id,omitempty"`
+	Networks []migrationPort `json:"networks"`
+	Started  time.Time       `json:"started"`
+	Duration string          `json:"duration"`
+	Seconds  float64         `json:"duration_seconds"`
+	Error    string          `json:"error,omitempty"`
+	Log      string          `json:"log"`
+}
+
+var migratePlanCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Run batches of migrations from a plan file",
+}
+
+var migratePlanApplyCmd = &cobra.Command{
+	Use:   "apply <plan.yaml>",
+	Short: "Migrate every VM in a plan file",
+	Long: `Migrate every VM listed in a YAML plan, running up to --parallel
+migrations at a time. Each VM logs to its own file in --log-dir; a failed VM
+doesn't stop the others. A JSON or CSV report (by --report extension) of VM
+IDs, MACs, durations and errors is written at the end.
+
+Example plan:
+  parallel: 4
+  network_map: portgroups.yaml
+  defaults:
+    volume_type: replica3
+  vms:
+    - vmx: /mnt/vmdk/ds1/web01/web01.vmx
+      source_vm: web01        # powered off through vCenter first
+    - ova: appliances/firewall.ova
+    - libvirt_xml: kvm/legacy01.xml
+    - name: db01
+      vmdks: [/mnt/vmdk/ds1/db01/db01.vmdk, /mnt/vmdk/ds1/db01/db01_1.vmdk]
+      flavor: m1.xlarge
+      networks: [backend]
+      macs: ["00:50:56:aa:bb:cc"]`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		plan, err := loadMigrationPlan(args[0])
+		if err != nil {
+			return err
+		}
+
+		parallel := plan.Parallel
+		if cmd.Flags().Changed("parallel") || parallel <= 0 {
+			parallel = migratePlanFlagParallel
+		}
+		if parallel < 1 {
+			return fmt.Errorf("--parallel must be at least 1")
+		}
+
+		upload, err := uploadOptionsFromFlags(migratePlanFlagBwLimit, migratePlanFlagRetries, migratePlanFlagStage)
+		if err != nil {
+			return err
+		}
+
+		stamp := time.Now().Format("20060102-150405")
+		logDir := migratePlanFlagLogDir
+		if logDir == "" {
+			logDir = fmt.Sprintf("migrate-logs-%s", stamp)
+		}
+		if err := os.MkdirAll(logDir, 0755); err != nil {
+			return fmt.Errorf("failed to create log directory: %v", err)
+		}
+
+		reportPath := migratePlanFlagReport
+		if reportPath == "" {
+			reportPath = fmt.Sprintf("migration-report-%s.json", stamp)
+		}
+
+		fmt.Printf("Migrating %d VM(s), %d at a time, logs in %s\n", len(plan.VMs), parallel, logDir)
+
+		reports := make([]migrationReport, len(plan.VMs))
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+
+		for w := 0; w < parallel; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					reports[i] = runPlanVM(computeURL, plan, plan.VMs[i], upload, logDir)
+
+					mu.Lock()
+					if reports[i].Error != "" {
+						fmt.Printf("FAILED  %s after %s: %s\n", reports[i].Name, reports[i].Duration, reports[i].Error)
+					} else {
+						fmt.Printf("OK      %s (%s) in %s\n", reports[i].Name, reports[i].VMID, reports[i].Duration)
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for i := range plan.VMs {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+
+		if err := writeMigrationReport(reportPath, reports); err != nil {
+			return err
+		}
+		fmt.Printf("Report written to %s\n", reportPath)
+
+		failed := 0
+		for _, r := range reports {
+			if r.Error != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d migrations failed; see %s", failed, len(reports), reportPath)
+		}
+		return nil
+	},
+}
+
+// loadMigrationPlan() reads and checks a plan file. Relative paths in the
+// plan are taken relative to the plan file.
+func loadMigrationPlan(path string) (migrationPlan, error) {
+	var plan migrationPlan
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return plan, fmt.Errorf("failed to read plan: %v", err)
+	}
+	if err := yaml.UnmarshalStrict(data, &plan); err != nil {
+		return plan, fmt.Errorf("failed to parse plan %s: %v", path, err)
+	}
+	if len(plan.VMs) == 0 {
+		return plan, fmt.Errorf("plan %s lists no VMs", path)
+	}
+
+	// defaults only cover settings shared across VMs; the rest would be
+	// silently ignored
+	d := plan.Defaults
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"name", d.Name != ""}, {"vmx", d.VMX != ""}, {"ova", d.OVA != ""}, {"libvirt_xml", d.LibvirtXML != ""},
+		{"vmdks", len(d.VMDKs) > 0}, {"macs", len(d.MACs) > 0}, {"size", d.Size != 0}, {"source_vm", d.SourceVM != ""},
+	} {
+		if f.set {
+			return plan, fmt.Errorf("plan %s: %s can't be set in defaults, only per VM", path, f.name)
+		}
+	}
+
+	dir := filepath.Dir(path)
+	relative := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	plan.NetworkMap = relative(plan.NetworkMap)
+
+	names := make(map[string]bool)
+	for i := range plan.VMs {
+		vm := &plan.VMs[i]
+		sources := 0
+		for _, set := range []bool{vm.VMX != "", vm.OVA != "", vm.LibvirtXML != "", len(vm.VMDKs) > 0} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return plan, fmt.Errorf("VM #%d of the plan needs exactly one of vmx, ova, libvirt_xml or vmdks", i+1)
+		}
+		vm.VMX = relative(vm.VMX)
+		vm.OVA = relative(vm.OVA)
+		vm.LibvirtXML = relative(vm.LibvirtXML)
+		for j := range vm.VMDKs {
+			vm.VMDKs[j] = relative(vm.VMDKs[j])
+		}
+
+		// VMX, OVA and libvirt entries take their name from the VM's if not given
+		if vm.Name == "" && vm.VMX != "" {
+			parsed, err := vmconfig.ParseVMX(vm.VMX)
+			if err != nil {
+				return plan, err
+			}
+			vm.Name = parsed.Name
+		}
+		if vm.Name == "" && vm.OVA != "" {
+			ova, err := vmconfig.OpenOVA(vm.OVA)
+			if err != nil {
+				return plan, err
+			}
+			parsed, err := ova.VM()
+			ova.Close()
+			if err != nil {
+				return plan, err
+			}
+			vm.Name = parsed.Name
+		}
+		if vm.Name == "" && vm.LibvirtXML != "" {
+			parsed, err := vmconfig.ParseLibvirtXML(vm.LibvirtXML)
+			if err != nil {
+				return plan, err
+			}
+			vm.Name = parsed.Name
+		}
+		if vm.Name == "" {
+			return plan, fmt.Errorf("VM #%d of the plan has no name", i+1)
+		}
+		if names[vm.Name] {
+			return plan, fmt.Errorf("VM %s is listed twice in the plan", vm.Name)
+		}
+		names[vm.Name] = true
+	}
+	return plan, nil
+}
+
+// runPlanVM() migrates one VM of a plan, logging to its own file
+func runPlanVM(computeURL string, plan migrationPlan, vm migrationPlanVM, upload api.UploadOptions, logDir string) migrationReport {
+	report := migrationReport{
+		Name:     vm.Name,
+		Status:   "ok",
+		Networks: []migrationPort{},
+		Started:  time.Now(),
+		Log:      filepath.Join(logDir, strings.ReplaceAll(vm.Name, "/", "_")+".log"),
+	}
+
+	logFile, err := os.Create(report.Log)
+	if err != nil {
+		report.Status = "failed"
+		report.Error = fmt.Sprintf("failed to create log file: %v", err)
+		return report
+	}
+	defer logFile.Close()
+
+	err = func() error {
+		spec := migrationSpec{
+			Name:       vm.Name,
+			Flavor:     firstNonEmpty(vm.Flavor, plan.Defaults.Flavor),
+			Networks:   vm.Networks,
+			MACs:       vm.MACs,
+			Size:       vm.Size,
+			DiskBus:    firstNonEmpty(vm.DiskBus, plan.Defaults.DiskBus),
+			VolumeType: firstNonEmpty(vm.VolumeType, plan.Defaults.VolumeType),
+			Shutdown:   vm.Shutdown || plan.Defaults.Shutdown,
+			Resume:     migratePlanFlagResume,
+			Sparse:     migratePlanFlagSparse || vm.Sparse || plan.Defaults.Sparse,
+			Upload:     upload,
+
+			SourceVM:      vm.SourceVM,
+			SourceTimeout: defaultSourceTimeout,
+		}
+
+		switch {
+		case vm.VMX != "":
+			if err := specFromVMX(computeURL, vm.VMX, plan.NetworkMap, &spec, logFile); err != nil {
+				return err
+			}
+		case vm.OVA != "":
+			if err := specFromOVA(computeURL, vm.OVA, plan.NetworkMap, &spec, logFile); err != nil {
+				return err
+			}
+		case vm.LibvirtXML != "":
+			if err := specFromLibvirt(computeURL, vm.LibvirtXML, plan.NetworkMap, &spec, logFile); err != nil {
+				return err
+			}
+		default:
+			for _, path := range vm.VMDKs {
+				spec.Disks = append(spec.Disks, vmconfig.Disk{Path: path})
+			}
+			if spec.Networks == nil {
+				spec.Networks = plan.Defaults.Networks
+			}
+			if spec.Networks == nil && viper.GetString("networks") != "" {
+				spec.Networks = strings.Split(viper.GetString("networks"), ",")
+			}
+			if len(spec.Networks) == 0 {
+				return fmt.Errorf("no networks specified for %s; set networks in the plan entry or its defaults, or 'networks' in config", vm.Name)
+			}
+			if spec.MACs == nil {
+				for range spec.Networks {
+					spec.MACs = append(spec.MACs, "auto")
+				}
+			}
+		}
+		if spec.Flavor == "" {
+			spec.Flavor = viper.GetString("flavor_id")
+		}
+		if spec.Flavor == "" {
+			return fmt.Errorf("no flavor for %s; set flavor in the plan or 'flavor_id' in config", vm.Name)
+		}
+
+		result, err := runMigration(spec, logFile)
+		report.VMID = result.VMID
+		report.Networks = result.Networks
+		if err != nil {
+			return err
+		}
+
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Fprintln(logFile, string(data))
+		return nil
+	}()
+
+	elapsed := time.Since(report.Started)
+	report.Duration = elapsed.Round(time.Second).String()
+	report.Seconds = elapsed.Seconds()
+	if err != nil {
+		fmt.Fprintf(logFile, "\nError: %v\n", err)
+		report.Status = "failed"
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// writeMigrationReport() writes reports as CSV if path ends in .csv,
+// JSON otherwise
+func writeMigrationReport(path string, reports []migrationReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report: %v", err)
+	}
+	defer f.Close()
+
+	if !strings.EqualFold(filepath.Ext(path), ".csv") {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(reports)
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"name", "status", "vm_id", "macs", "started", "duration_seconds", "error", "log"})
+	for _, r := range reports {
+		var macs []string
+		for _, p := range r.Networks {
+			macs = append(macs, fmt.Sprintf("%s=%s", p.NetworkID, p.MACAddress))
+		}
+		w.Write([]string{
+			r.Name,
+			r.Status,
+			r.VMID,
+			strings.Join(macs, ";"),
+			r.Started.Format(time.RFC3339),
+			strconv.FormatFloat(r.Seconds, 'f', 0, 64),
+			r.Error,
+			r.Log,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// firstNonEmpty() returns the first of values that isn't empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+var (
+	migratePlanFlagParallel int
+	migratePlanFlagLogDir   string
+	migratePlanFlagReport   string
+	migratePlanFlagBwLimit  string
+	migratePlanFlagRetries  int
+	migratePlanFlagStage    bool
+	migratePlanFlagSparse   bool
+	migratePlanFlagResume   bool
+)
+
+func init() {
+	migratePlanApplyCmd.Flags().IntVar(&migratePlanFlagParallel, "parallel", 2, "Number of migrations to run at once (overrides 'parallel' in the plan)")
+	migratePlanApplyCmd.Flags().StringVar(&migratePlanFlagLogDir, "log-dir", "", "Directory for per-VM logs (default: migrate-logs-<timestamp>)")
+	migratePlanApplyCmd.Flags().StringVar(&migratePlanFlagReport, "report", "", "Report file, .json or .csv (default: migration-report-<timestamp>.json)")
+	migratePlanApplyCmd.Flags().StringVar(&migratePlanFlagBwLimit, "bwlimit", "", "Limit the bandwidth of each upload in bytes/s, e.g. 50M (default: unlimited)")
+	migratePlanApplyCmd.Flags().IntVar(&migratePlanFlagRetries, "retries", 3, "Retry a failed upload this many times; each retry resends the file from the start")
+	migratePlanApplyCmd.Flags().BoolVar(&migratePlanFlagStage, "stage", false, "Upload via Glance stage + glance-direct import instead of a direct PUT")
+	migratePlanApplyCmd.Flags().BoolVar(&migratePlanFlagSparse, "sparse", false, "Convert raw and flat disks to qcow2 while uploading, leaving out holes and zeroed blocks (or 'sparse' in the plan)")
+
+	migratePlanApplyCmd.Flags().BoolVar(&migratePlanFlagResume, "resume", false, "Continue failed migrations of the plan's VMs from their last completed stage")
+
+	migratePlanCmd.AddCommand(migratePlanApplyCmd)
+	migrateCmd.AddCommand(migratePlanCmd)
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jessegalley/vhicmd/internal/vsphere"
+	"github.com/spf13/viper"
+)
+
+// defaultSourceTimeout is how long a graceful shutdown of the source VM
+// may take before it is powered off hard
+const defaultSourceTimeout = 5 * time.Minute
+
+// quiesceTimeout is how long to wait for the disks of a stopped source VM
+// to be unlocked and stop changing
+const quiesceTimeout = 2 * time.Minute
+
+// vcenterLogin() opens a session on the vCenter from config
+func vcenterLogin() (*vsphere.Client, error) {
+	host := viper.GetString("vcenter_host")
+	username := viper.GetString("vcenter_username")
+	if host == "" || username == "" {
+		return nil, fmt.Errorf("no vCenter configured; set vcenter_host, vcenter_username and vcenter_password in config")
+	}
+	return vsphere.Login(host, username, viper.GetString("vcenter_password"), viper.GetBool("vcenter_insecure"))
+}
+
+// stopSourceVM() powers off the vSphere VM being migrated: a guest
+// shutdown through VMware Tools first, a hard power off if that fails or
+// takes longer than timeout. Then waits for its disks to be quiesced.
+func stopSourceVM(name string, disks []*migrationDisk, timeout time.Duration, out io.Writer) error {
+	vc, err := vcenterLogin()
+	if err != nil {
+		return err
+	}
+	defer vc.Logout()
+
+	vm, err := vc.FindVM(name)
+	if err != nil {
+		return err
+	}
+
+	if vm.PowerState != vsphere.PoweredOff {
+		fmt.Fprintf(out, "Shutting down vSphere VM '%s' (%s)...\n", vm.Name, vm.ID)
+		err := vc.ShutdownGuest(vm.ID)
+		if err == nil {
+			err = vc.WaitForPowerState(vm.ID, vsphere.PoweredOff, timeout)
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Graceful shutdown failed (%v), powering off\n", err)
+			if err := vc.PowerOff(vm.ID); err != nil {
+				return err
+			}
+			if err := vc.WaitForPowerState(vm.ID, vsphere.PoweredOff, time.Minute); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintf(out, "vSphere VM '%s' is powered off\n", vm.Name)
+
+	return waitForQuiescedDisks(disks, quiesceTimeout, out)
+}
+
+// waitForQuiescedDisks() waits until no disk has lock files and none of
+// their files changed size or mtime between two polls
+func waitForQuiescedDisks(disks []*migrationDisk, timeout time.Duration, out io.Writer) error {
+	deadline := time.Now().Add(timeout)
+	previous := ""
+	for {
+		var locks []string
+		var snapshot strings.Builder
+		for _, d := range disks {
+			if d.desc == nil {
+				continue
+			}
+			locks = append(locks, d.desc.Locks()...)
+
+			files := []string{d.desc.Path}
+			for _, e := range d.desc.Extents {
+				files = append(files, e.Path)
+			}
+			for _, f := range files {
+				if info, err := os.Stat(f); err == nil {
+					fmt.Fprintf(&snapshot, "%s %d %d\n", f, info.Size(), info.ModTime().UnixNano())
+				}
+			}
+		}
+
+		if len(locks) == 0 && snapshot.String() == previous {
+			fmt.Fprintln(out, "Source disks are quiesced")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if len(locks) > 0 {
+				return fmt.Errorf("source disks still locked after %s: %s", timeout, strings.Join(locks, ", "))
+			}
+			return fmt.Errorf("source disks still changing after %s", timeout)
+		}
+
+		previous = snapshot.String()
+		time.Sleep(5 * time.Second)
+	}
+}
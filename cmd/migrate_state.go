@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/responseparser"
+	"github.com/spf13/cobra"
+)
+
+// Migration stages, in the order they complete
+const (
+	stageStarted       = "started"
+	stageImageUploaded = "image-uploaded"
+	stageVMCreated     = "vm-created"
+	stagePortsAttached = "ports-attached"
+	stageImageDeleted  = "image-deleted"
+)
+
+// migrationState records how far a migration got, so a rerun with
+// --resume can skip the stages already done
+type migrationState struct {
+	Name    string               `json:"name"`
+	Host    string               `json:"host"`
+	Project string               `json:"project"`
+	Status  string               `json:"status"` // in-progress, failed, completed
+	Stage   string               `json:"stage"`  // last stage completed
+	Error   string               `json:"error,omitempty"`
+	Started time.Time            `json:"started"`
+	Updated time.Time            `json:"updated"`
+	Disks   []migrationStateDisk `json:"disks"`
+	VMID    string               `json:"vm_id,omitempty"`
+	Ports   []migrationStatePort `json:"ports"`
+	Stopped bool                 `json:"stopped,omitempty"` // the VHI VM, by --shutdown
+
+	// SourceStopped is set once the vSphere source VM was powered off
+	SourceStopped bool `json:"source_stopped,omitempty"`
+
+	path string
+}
+
+// migrationStateDisk is an uploaded temporary image
+type migrationStateDisk struct {
+	Path         string `json:"path"`
+	ImageID      string `json:"image_id,omitempty"`
+	ImageDeleted bool   `json:"image_deleted,omitempty"`
+}
+
+// migrationStatePort is a port created for the VM, recorded before it's
+// attached so a resumed run reuses it instead of clashing on its MAC
+type migrationStatePort struct {
+	NetworkID  string `json:"network_id"`
+	PortID     string `json:"port_id"`
+	MACAddress string `json:"mac_address"`
+	Attached   bool   `json:"attached"`
+}
+
+// migrationStateDir() returns the directory state files are kept in,
+// next to the token file
+func migrationStateDir() string {
+	return filepath.Join(filepath.Dir(api.TokenFile), ".vhicmd", "migrations")
+}
+
+// migrationStatePath() returns the state file of the VM named name
+func migrationStatePath(name string) string {
+	return filepath.Join(migrationStateDir(), strings.ReplaceAll(name, "/", "_")+".json")
+}
+
+// loadMigrationState() reads the state of the VM named name, returning
+// nil if there is none
+func loadMigrationState(name string) (*migrationState, error) {
+	path := migrationStatePath(name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration state: %v", err)
+	}
+
+	var state migrationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse migration state %s: %v", path, err)
+	}
+	state.path = path
+	return &state, nil
+}
+
+// startMigrationState() returns the state to run spec with: the saved one
+// when resuming, otherwise a fresh one. An unfinished migration of the
+// same VM is only continued with --resume, except one that failed before
+// changing anything, e.g. on a flavor typo, which a rerun replaces.
+func startMigrationState(spec migrationSpec) (*migrationState, error) {
+	state, err := loadMigrationState(spec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if state != nil && state.Status != "completed" && !(state.Status == "failed" && state.untouched()) {
+		if !spec.Resume {
+			return nil, fmt.Errorf("a previous migration of %s stopped after stage %s; rerun with --resume, or clear it with 'vhicmd migrate status --clear %s'",
+				spec.Name, state.Stage, spec.Name)
+		}
+		if state.Host != tok.Host || state.Project != tok.Project {
+			return nil, fmt.Errorf("the previous migration of %s ran against %s/%s, not %s/%s", spec.Name, state.Host, state.Project, tok.Host, tok.Project)
+		}
+		if len(state.Disks) != len(spec.Disks) {
+			return nil, fmt.Errorf("the previous migration of %s had %d disk(s), now %d; clear it to start over", spec.Name, len(state.Disks), len(spec.Disks))
+		}
+		state.Status = "in-progress"
+		state.Error = ""
+		return state, state.save()
+	}
+
+	state = &migrationState{
+		Name:    spec.Name,
+		Host:    tok.Host,
+		Project: tok.Project,
+		Status:  "in-progress",
+		Stage:   stageStarted,
+		Started: time.Now(),
+		Ports:   []migrationStatePort{},
+		path:    migrationStatePath(spec.Name),
+	}
+	for _, d := range spec.Disks {
+		state.Disks = append(state.Disks, migrationStateDisk{Path: d.Path})
+	}
+	return state, state.save()
+}
+
+// untouched() reports whether the migration failed or stopped before its
+// first side effect: no source power-off, image, VM or port yet
+func (s *migrationState) untouched() bool {
+	if s.Stage != stageStarted || s.SourceStopped || s.Stopped || s.VMID != "" || len(s.Ports) > 0 {
+		return false
+	}
+	for _, d := range s.Disks {
+		if d.ImageID != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// save() writes the state file, replacing it atomically
+func (s *migrationState) save() error {
+	s.Updated = time.Now()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write migration state: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// migrationStages lists the stages in order, for comparing progress
+var migrationStages = []string{stageStarted, stageImageUploaded, stageVMCreated, stagePortsAttached, stageImageDeleted}
+
+// reached() records that stage has completed; a resumed run passing an
+// earlier stage again doesn't move the recorded stage back
+func (s *migrationState) reached(stage string) error {
+	if slices.Index(migrationStages, stage) > slices.Index(migrationStages, s.Stage) {
+		s.Stage = stage
+	}
+	return s.save()
+}
+
+// finish() records the outcome of the run
+func (s *migrationState) finish(err error) {
+	s.Status = "completed"
+	if err != nil {
+		s.Status = "failed"
+		s.Error = err.Error()
+	}
+	_ = s.save()
+}
+
+// listMigrationStates() returns all saved migration states, oldest first
+func listMigrationStates() ([]migrationState, error) {
+	entries, err := os.ReadDir(migrationStateDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state directory: %v", err)
+	}
+
+	var states []migrationState
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(migrationStateDir(), entry.Name()))
+		if err != nil {
+			continue
+		}
+		var state migrationState
+		if err := json.Unmarshal(data, &state); err != nil {
+			continue
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Started.Before(states[j].Started)
+	})
+	return states, nil
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "List migrations that are in progress or failed",
+	Long: `List migrations that are in progress or failed, with the last stage
+each completed. Failed migrations continue with 'migrate vm --resume'.
+Use --all to include completed migrations, --clear <name> to forget one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if migrateStatusFlagClear != "" {
+			if err := os.Remove(migrationStatePath(migrateStatusFlagClear)); err != nil {
+				return fmt.Errorf("failed to clear migration state: %v", err)
+			}
+			fmt.Printf("Cleared migration state of %s\n", migrateStatusFlagClear)
+			return nil
+		}
+
+		states, err := listMigrationStates()
+		if err != nil {
+			return err
+		}
+
+		var shown []migrationState
+		for _, s := range states {
+			if migrateStatusFlagAll || s.Status != "completed" {
+				shown = append(shown, s)
+			}
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(shown, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+
+		if len(shown) == 0 {
+			fmt.Println("No unfinished migrations.")
+			return nil
+		}
+
+		var rows []responseparser.MigrationStatus
+		for _, s := range shown {
+			rows = append(rows, responseparser.MigrationStatus{
+				Name:          s.Name,
+				Status:        s.Status,
+				Stage:         s.Stage,
+				SourceStopped: s.SourceStopped,
+				VMID:          s.VMID,
+				Updated:       s.Updated.Format("2006-01-02 15:04:05"),
+				Error:         s.Error,
+			})
+		}
+		responseparser.PrintMigrationStatusTable(rows)
+		return nil
+	},
+}
+
+var (
+	migrateStatusFlagAll   bool
+	migrateStatusFlagClear string
+)
+
+func init() {
+	migrateStatusCmd.Flags().BoolVar(&migrateStatusFlagAll, "all", false, "Include completed migrations")
+	migrateStatusCmd.Flags().StringVar(&migrateStatusFlagClear, "clear", "", "Forget the saved state of the named migration")
+	migrateStatusCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+
+	migrateCmd.AddCommand(migrateStatusCmd)
+}
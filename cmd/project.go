@@ -0,0 +1,654 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Create and delete projects [Req: admin]",
+}
+
+var projectCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Onboard a project: roles, quotas and a default network",
+	Long: `Create a Keystone project in one go for customer onboarding: grant --role
+(default member) to every --user, set its compute, volume and network quotas,
+and with --network create a private network, subnet and a router to the
+external network.
+
+Quotas are resource=value pairs: instances, cores, ram (MB or e.g. 64G),
+volumes, gigabytes, snapshots, gigabytes_<volume type>, ports, networks,
+subnets, routers, floating_ips, security_groups; -1 is unlimited.
+
+If a step fails once the project exists, the project and what was already
+created in it are deleted again.
+
+Examples:
+  vhicmd project create acme --domain customers --user alice --quota cores=32,ram=64G,gigabytes=2000
+  vhicmd project create acme --user alice --user bob --role member --role admin \
+    --network acme-net --cidr 10.10.0.0/24 --external-network public`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		identityURL, err := validateTokenEndpoint(tok, "identity")
+		if err != nil {
+			return err
+		}
+
+		// everything is looked up and parsed before the project exists
+		compute, volume, network, err := parseQuotaFlags(flagProjectQuotas)
+		if err != nil {
+			return err
+		}
+
+		domain := flagProjectDomain
+		if domain == "" {
+			domain = viper.GetString("domain")
+		}
+		if domain == "" {
+			return fmt.Errorf("no domain specified; provide --domain or set 'domain' in config")
+		}
+		domainID := resolveDomainID(identityURL, domain)
+
+		var userIDs, roleIDs []string
+		for _, user := range flagProjectUsers {
+			id, err := resolveUserID(identityURL, user, domainID)
+			if err != nil {
+				return err
+			}
+			userIDs = append(userIDs, id)
+		}
+		for _, role := range flagProjectRoles {
+			id, err := api.GetRoleIDByName(identityURL, tok.Value, role)
+			if err != nil {
+				return err
+			}
+			roleIDs = append(roleIDs, id)
+		}
+
+		var externalID string
+		if flagProjectNetwork != "" {
+			_, subnet, err := net.ParseCIDR(flagProjectCIDR)
+			if err != nil || subnet.IP.To4() == nil {
+				return fmt.Errorf("invalid --cidr %q; expected an IPv4 CIDR such as 192.168.0.0/24", flagProjectCIDR)
+			}
+			networkURL, err := validateTokenEndpoint(tok, "network")
+			if err != nil {
+				return err
+			}
+			if externalID, err = resolveExternalNetwork(networkURL, flagProjectExternal); err != nil {
+				return err
+			}
+		}
+
+		project, err := api.CreateProject(identityURL, tok.Value, args[0], domainID, flagProjectDescription)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Project %s created: %s\n", project.Name, project.ID)
+
+		if err := onboardProject(identityURL, project.ID, userIDs, roleIDs, compute, volume, network, externalID); err != nil {
+			return rollbackProject(identityURL, project, err)
+		}
+		return nil
+	},
+}
+
+// onboardProject() grants the roles, sets the quotas and creates the
+// network of a new project
+func onboardProject(identityURL, projectID string, userIDs, roleIDs []string, compute, volume, network map[string]int, externalID string) error {
+	for i, userID := range userIDs {
+		for j, roleID := range roleIDs {
+			if err := api.AssignProjectRole(identityURL, tok.Value, projectID, userID, roleID); err != nil {
+				return err
+			}
+			fmt.Printf("Granted role %s to user %s\n", flagProjectRoles[j], flagProjectUsers[i])
+		}
+	}
+
+	if err := setProjectQuotas(projectID, compute, volume, network); err != nil {
+		return err
+	}
+
+	if flagProjectNetwork != "" {
+		if err := createProjectNetwork(projectID, flagProjectNetwork, flagProjectCIDR, externalID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackProject() deletes a project whose onboarding failed with
+// cause, and what was already created in it, so a rerun starts clean
+func rollbackProject(identityURL string, project api.ProjectDetail, cause error) error {
+	fmt.Printf("Onboarding failed: %v\nRolling back project %s (%s)\n", cause, project.Name, project.ID)
+	err := deleteProjectResources(project.ID)
+	if err == nil {
+		err = api.DeleteProject(identityURL, tok.Value, project.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("%v; rolling back failed too (%v), project %s is left behind: remove it with 'vhicmd project delete %s --cascade'",
+			cause, err, project.ID, project.ID)
+	}
+	return fmt.Errorf("%v; project %s was rolled back", cause, project.Name)
+}
+
+var projectDeleteCmd = &cobra.Command{
+	Use:   "delete <project>",
+	Short: "Delete a project, with --cascade everything in it",
+	Long: `Delete a Keystone project. A project that still owns VMs, volumes or
+networks is refused unless --cascade is given, which first deletes its VMs,
+floating IPs, routers, ports, networks, security groups and volumes, and
+resets its quotas. If anything can't be deleted the project is kept; rerun
+the command once the cause is fixed.
+
+The project is a project ID, or a name that has to match exactly one
+project, in --domain if given. --cascade asks for confirmation unless --yes
+is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		identityURL, err := validateTokenEndpoint(tok, "identity")
+		if err != nil {
+			return err
+		}
+
+		project, err := findProject(identityURL, args[0], flagProjectDomain)
+		if err != nil {
+			return err
+		}
+		if project.ID == currentProjectID() {
+			return fmt.Errorf("refusing to delete the project of the current token")
+		}
+
+		owned, err := projectResources(project.ID)
+		if err != nil {
+			return fmt.Errorf("%v; not deleting project %s", err, project.Name)
+		}
+		if flagProjectCascade {
+			if !flagProjectYes {
+				fmt.Printf("Project %s (ID %s, domain %s) owns %s.\n", project.Name, project.ID, project.DomainID, firstNonEmpty(strings.Join(owned, ", "), "no VMs, volumes or networks"))
+				ok, err := readConfirmation("Delete the project and everything in it? [y/N] ")
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("aborted")
+				}
+			}
+			if err := deleteProjectResources(project.ID); err != nil {
+				return err
+			}
+		} else if len(owned) > 0 {
+			return fmt.Errorf("project %s still owns %s; delete them first or use --cascade", args[0], strings.Join(owned, ", "))
+		}
+
+		if err := api.DeleteProject(identityURL, tok.Value, project.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Project %s (%s) deleted\n", project.Name, project.ID)
+		return nil
+	},
+}
+
+// computeQuotaKeys and volumeQuotaKeys are the --quota resources set
+// through Nova and Cinder; the rest go to Neutron
+var (
+	computeQuotaKeys = []string{"instances", "cores", "ram", "key_pairs", "server_groups", "server_group_members", "metadata_items"}
+	volumeQuotaKeys  = []string{"volumes", "gigabytes", "snapshots", "backups", "backup_gigabytes", "per_volume_gigabytes"}
+)
+
+// networkQuotaAliases maps plural --quota names to Neutron resources
+var networkQuotaAliases = map[string]string{
+	"ports":                "port",
+	"networks":             "network",
+	"subnets":              "subnet",
+	"routers":              "router",
+	"floating_ips":         "floatingip",
+	"floatingips":          "floatingip",
+	"security_groups":      "security_group",
+	"security_group_rules": "security_group_rule",
+}
+
+// parseQuotaFlags() splits resource=value quota pairs by service
+func parseQuotaFlags(pairs []string) (compute, volume, network map[string]int, err error) {
+	compute, volume, network = map[string]int{}, map[string]int{}, map[string]int{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return nil, nil, nil, fmt.Errorf("invalid quota %q; expected resource=value", pair)
+		}
+		key = strings.ToLower(key)
+
+		var n int
+		if key == "ram" && value != "-1" {
+			n, err = parseRAMMB(value)
+		} else {
+			n, err = strconv.Atoi(value)
+			if err == nil && n < -1 {
+				err = fmt.Errorf("must be -1 (unlimited) or more")
+			}
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid quota %q: %v", pair, err)
+		}
+
+		switch {
+		case slices.Contains(computeQuotaKeys, key):
+			compute[key] = n
+		case slices.Contains(volumeQuotaKeys, key),
+			strings.HasPrefix(key, "volumes_"), strings.HasPrefix(key, "gigabytes_"), strings.HasPrefix(key, "snapshots_"):
+			volume[key] = n
+		case networkQuotaAliases[key] != "":
+			network[networkQuotaAliases[key]] = n
+		case slices.Contains([]string{"port", "network", "subnet", "router", "floatingip", "security_group", "security_group_rule"}, key):
+			network[key] = n
+		default:
+			return nil, nil, nil, fmt.Errorf("unknown quota resource %q", key)
+		}
+	}
+	return compute, volume, network, nil
+}
+
+// setProjectQuotas() sets the quotas of each service that has any
+func setProjectQuotas(projectID string, compute, volume, network map[string]int) error {
+	if len(compute) > 0 {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+		if err := api.SetComputeQuotas(computeURL, tok.Value, projectID, compute); err != nil {
+			return err
+		}
+		fmt.Printf("Compute quotas set: %s\n", formatQuotas(compute))
+	}
+	if len(volume) > 0 {
+		storageURL, err := validateTokenEndpoint(tok, "volumev3")
+		if err != nil {
+			return err
+		}
+		if err := api.SetVolumeQuotas(storageURL, tok.Value, projectID, volume); err != nil {
+			return err
+		}
+		fmt.Printf("Volume quotas set: %s\n", formatQuotas(volume))
+	}
+	if len(network) > 0 {
+		networkURL, err := validateTokenEndpoint(tok, "network")
+		if err != nil {
+			return err
+		}
+		if err := api.SetNetworkQuotas(networkURL, tok.Value, projectID, network); err != nil {
+			return err
+		}
+		fmt.Printf("Network quotas set: %s\n", formatQuotas(network))
+	}
+	return nil
+}
+
+// formatQuotas() renders quotas as sorted resource=value pairs
+func formatQuotas(quotas map[string]int) string {
+	var pairs []string
+	for key, value := range quotas {
+		pairs = append(pairs, fmt.Sprintf("%s=%d", key, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// createProjectNetwork() creates a private network and subnet owned by
+// the project and a router from it to the external network externalID
+func createProjectNetwork(projectID, name, cidr, externalID string) error {
+	networkURL, err := validateTokenEndpoint(tok, "network")
+	if err != nil {
+		return err
+	}
+
+	network, err := api.CreateNetwork(networkURL, tok.Value, name, projectID)
+	if err != nil {
+		return err
+	}
+	subnetID, err := api.CreateSubnet(networkURL, tok.Value, network.ID, name+"-subnet", cidr, projectID, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Network %s created: %s (%s)\n", name, network.ID, cidr)
+
+	router, err := api.CreateRouter(networkURL, tok.Value, name+"-router", projectID, externalID)
+	if err != nil {
+		return err
+	}
+	if err := api.AddRouterInterface(networkURL, tok.Value, router.ID, subnetID); err != nil {
+		return err
+	}
+	fmt.Printf("Router %s created: %s, gateway on %s\n", router.Name, router.ID, externalID)
+	return nil
+}
+
+// resolveExternalNetwork() returns the ID of the external network named
+// nameOrID, or of the only external network if it's empty
+func resolveExternalNetwork(networkURL, nameOrID string) (string, error) {
+	resp, err := api.ListNetworks(networkURL, tok.Value, map[string]string{"router:external": "true"})
+	if err != nil {
+		return "", err
+	}
+
+	var matches []api.Network
+	for _, n := range resp.Networks {
+		if nameOrID == "" || n.ID == nameOrID || n.Name == nameOrID {
+			matches = append(matches, n)
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		return matches[0].ID, nil
+	case nameOrID != "" && len(matches) == 0:
+		return "", fmt.Errorf("no external network %s", nameOrID)
+	case nameOrID != "":
+		return "", fmt.Errorf("multiple external networks named %s; give its ID", nameOrID)
+	case len(matches) == 0:
+		return "", fmt.Errorf("no external network for the router")
+	}
+	return "", fmt.Errorf("%d external networks; choose one with --external-network", len(matches))
+}
+
+// projectResources() lists what a project still owns, e.g. "2 VM(s)".
+// A listing that fails is an error, so the project never looks emptier
+// than it is.
+func projectResources(projectID string) ([]string, error) {
+	var owned []string
+
+	computeURL, err := validateTokenEndpoint(tok, "compute")
+	if err != nil {
+		return nil, err
+	}
+	vms, err := api.ListVMs(computeURL, tok.Value, map[string]string{"all_tenants": "1", "project_id": projectID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the project's VMs: %v", err)
+	}
+	if len(vms.Servers) > 0 {
+		owned = append(owned, fmt.Sprintf("%d VM(s)", len(vms.Servers)))
+	}
+
+	storageURL, err := validateTokenEndpoint(tok, "volumev3")
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := api.ListVolumes(storageURL, tok.Value, map[string]string{"all_tenants": "1", "project_id": projectID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the project's volumes: %v", err)
+	}
+	if len(volumes.Volumes) > 0 {
+		owned = append(owned, fmt.Sprintf("%d volume(s)", len(volumes.Volumes)))
+	}
+
+	networkURL, err := validateTokenEndpoint(tok, "network")
+	if err != nil {
+		return nil, err
+	}
+	networks, err := api.ListNetworks(networkURL, tok.Value, map[string]string{"project_id": projectID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the project's networks: %v", err)
+	}
+	if len(networks.Networks) > 0 {
+		owned = append(owned, fmt.Sprintf("%d network(s)", len(networks.Networks)))
+	}
+	return owned, nil
+}
+
+// deleteProjectResources() deletes what a project owns, VMs first so
+// their ports and volumes are released. Failures are collected so one
+// stuck resource doesn't hide the others.
+func deleteProjectResources(projectID string) error {
+	computeURL, err := validateTokenEndpoint(tok, "compute")
+	if err != nil {
+		return err
+	}
+	storageURL, err := validateTokenEndpoint(tok, "volumev3")
+	if err != nil {
+		return err
+	}
+	networkURL, err := validateTokenEndpoint(tok, "network")
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	fail := func(err error) {
+		fmt.Printf("  %v\n", err)
+		failed = append(failed, err.Error())
+	}
+	inProject := map[string]string{"project_id": projectID}
+
+	vmFilter := map[string]string{"all_tenants": "1", "project_id": projectID}
+	vms, err := api.ListVMs(computeURL, tok.Value, vmFilter)
+	if err != nil {
+		return err
+	}
+	for _, vm := range vms.Servers {
+		fmt.Printf("Deleting VM %s (%s)\n", vm.Name, vm.ID)
+		if err := api.DeleteVM(computeURL, tok.Value, vm.ID); err != nil {
+			fail(err)
+		}
+	}
+	if len(vms.Servers) > 0 {
+		if err := waitForNoVMs(computeURL, vmFilter, 10*time.Minute); err != nil {
+			return err
+		}
+	}
+
+	fips, err := api.ListFloatingIPs(networkURL, tok.Value, inProject)
+	if err != nil {
+		return err
+	}
+	for _, fip := range fips {
+		fmt.Printf("Releasing floating IP %s\n", fip.FloatingIPAddress)
+		if err := api.DeleteFloatingIP(networkURL, tok.Value, fip.ID); err != nil {
+			fail(err)
+		}
+	}
+
+	routers, err := api.ListRouters(networkURL, tok.Value, inProject)
+	if err != nil {
+		return err
+	}
+	for _, router := range routers {
+		ports, err := api.ListPorts(networkURL, tok.Value, map[string]string{"device_id": router.ID})
+		if err != nil {
+			return err
+		}
+		for _, port := range ports.Ports {
+			if port.DeviceOwner != "network:router_interface" && port.DeviceOwner != "network:router_interface_distributed" {
+				continue
+			}
+			if err := api.RemoveRouterInterface(networkURL, tok.Value, router.ID, port.ID); err != nil {
+				fail(err)
+			}
+		}
+		fmt.Printf("Deleting router %s (%s)\n", router.Name, router.ID)
+		if err := api.DeleteRouter(networkURL, tok.Value, router.ID); err != nil {
+			fail(err)
+		}
+	}
+
+	// DHCP and router ports go with their network and router
+	ports, err := api.ListPorts(networkURL, tok.Value, inProject)
+	if err != nil {
+		return err
+	}
+	for _, port := range ports.Ports {
+		if strings.HasPrefix(port.DeviceOwner, "network:") {
+			continue
+		}
+		fmt.Printf("Deleting port %s (%s)\n", port.ID, port.MACAddress)
+		if err := api.DeletePort(networkURL, tok.Value, port.ID); err != nil {
+			fail(err)
+		}
+	}
+
+	networks, err := api.ListNetworks(networkURL, tok.Value, inProject)
+	if err != nil {
+		return err
+	}
+	for _, network := range networks.Networks {
+		fmt.Printf("Deleting network %s (%s)\n", network.Name, network.ID)
+		if err := api.DeleteNetwork(networkURL, tok.Value, network.ID); err != nil {
+			fail(err)
+		}
+	}
+
+	groups, err := api.ListSecurityGroups(networkURL, tok.Value, inProject)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		fmt.Printf("Deleting security group %s (%s)\n", group.Name, group.ID)
+		if err := api.DeleteSecurityGroup(networkURL, tok.Value, group.ID); err != nil {
+			fail(err)
+		}
+	}
+
+	volumes, err := api.ListVolumes(storageURL, tok.Value, map[string]string{"all_tenants": "1", "project_id": projectID})
+	if err != nil {
+		return err
+	}
+	for _, volume := range volumes.Volumes {
+		if volume.Status == "deleting" {
+			continue
+		}
+		fmt.Printf("Deleting volume %s (%s, %d GB)\n", volume.Name, volume.ID, volume.Size)
+		if err := api.DeleteVolume(storageURL, tok.Value, volume.ID); err != nil {
+			fail(err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d resource(s) of project %s could not be deleted; the project was kept", len(failed), projectID)
+	}
+
+	if err := api.ResetQuotas(computeURL, storageURL, networkURL, tok.Value, projectID); err != nil {
+		return err
+	}
+	return nil
+}
+
+// waitForNoVMs() waits until no VM matches filter, deleted VMs included
+func waitForNoVMs(computeURL string, filter map[string]string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		vms, err := api.ListVMs(computeURL, tok.Value, filter)
+		if err != nil {
+			return err
+		}
+		if len(vms.Servers) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d VM(s) still not deleted after %s", len(vms.Servers), timeout)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// findProject() returns the project with ID nameOrID, or the only one
+// named nameOrID, in domain if it's set. Unlike resolveProjectID it never
+// guesses: a name shared across domains or unknown is an error.
+func findProject(identityURL, nameOrID, domain string) (api.ProjectDetail, error) {
+	domainID := ""
+	if domain != "" {
+		d, err := api.GetDomain(identityURL, tok.Value, domain)
+		if err == nil {
+			domainID = d.ID
+		} else if domainID, err = api.GetDomainIDByName(identityURL, tok.Value, domain); err != nil {
+			return api.ProjectDetail{}, err
+		}
+	}
+
+	if project, err := api.GetProject(identityURL, tok.Value, nameOrID); err == nil {
+		if domainID == "" || project.DomainID == domainID {
+			return project, nil
+		}
+	}
+
+	projects, err := api.FindProjectsByName(identityURL, tok.Value, nameOrID, domainID)
+	if err != nil {
+		return api.ProjectDetail{}, err
+	}
+	switch len(projects) {
+	case 0:
+		if domain != "" {
+			return api.ProjectDetail{}, fmt.Errorf("no project %s in domain %s", nameOrID, domain)
+		}
+		return api.ProjectDetail{}, fmt.Errorf("no project %s", nameOrID)
+	case 1:
+		return projects[0], nil
+	}
+	var domains []string
+	for _, p := range projects {
+		domains = append(domains, p.DomainID)
+	}
+	return api.ProjectDetail{}, fmt.Errorf("%d projects named %s, in domains %s; give its domain or the project ID", len(projects), nameOrID, strings.Join(domains, ", "))
+}
+
+// resolveDomainID returns the ID of the domain named nameOrID, or
+// nameOrID unchanged if it can't be looked up.
+func resolveDomainID(identityURL, nameOrID string) string {
+	id, err := api.GetDomainIDByName(identityURL, tok.Value, nameOrID)
+	if err == nil {
+		return id
+	}
+	return nameOrID
+}
+
+// resolveUserID() returns the ID of the user named nameOrID in domainID,
+// or nameOrID itself if it's a user ID
+func resolveUserID(identityURL, nameOrID, domainID string) (string, error) {
+	id, err := api.GetUserIDByName(identityURL, tok.Value, nameOrID, domainID)
+	if err == nil {
+		return id, nil
+	}
+	if _, detailErr := api.GetUser(identityURL, tok.Value, nameOrID); detailErr == nil {
+		return nameOrID, nil
+	}
+	return "", err
+}
+
+var (
+	flagProjectDomain      string
+	flagProjectDescription string
+	flagProjectUsers       []string
+	flagProjectRoles       []string
+	flagProjectQuotas      []string
+	flagProjectNetwork     string
+	flagProjectCIDR        string
+	flagProjectExternal    string
+	flagProjectCascade     bool
+	flagProjectYes         bool
+)
+
+func init() {
+	projectCreateCmd.Flags().StringVar(&flagProjectDomain, "domain", "", "Domain name or ID (default: 'domain' from config)")
+	projectCreateCmd.Flags().StringVar(&flagProjectDescription, "description", "", "Project description")
+	projectCreateCmd.Flags().StringArrayVar(&flagProjectUsers, "user", nil, "User (name in the domain, or ID) to grant the roles, repeatable")
+	projectCreateCmd.Flags().StringArrayVar(&flagProjectRoles, "role", []string{"member"}, "Role to grant every --user, repeatable")
+	projectCreateCmd.Flags().StringSliceVar(&flagProjectQuotas, "quota", nil, "Quotas as resource=value, comma-separated or repeated, e.g. cores=32,ram=64G,gigabytes=2000")
+	projectCreateCmd.Flags().StringVar(&flagProjectNetwork, "network", "", "Create a private network of this name, with a subnet and a router")
+	projectCreateCmd.Flags().StringVar(&flagProjectCIDR, "cidr", "192.168.0.0/24", "CIDR of the --network subnet")
+	projectCreateCmd.Flags().StringVar(&flagProjectExternal, "external-network", "", "External network for the router gateway (default: the only external network)")
+
+	projectDeleteCmd.Flags().BoolVar(&flagProjectCascade, "cascade", false, "Delete the project's VMs, volumes, networks, routers and floating IPs first")
+	projectDeleteCmd.Flags().StringVar(&flagProjectDomain, "domain", "", "Domain (name or ID) to look the project name up in")
+	projectDeleteCmd.Flags().BoolVarP(&flagProjectYes, "yes", "y", false, "Don't ask for confirmation before --cascade")
+
+	projectCmd.AddCommand(projectCreateCmd)
+	projectCmd.AddCommand(projectDeleteCmd)
+	rootCmd.AddCommand(projectCmd)
+}
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/responseparser"
+	"github.com/spf13/cobra"
+)
+
+var quotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Show and set project quotas",
+}
+
+// quotaRow is one resource of 'quota show'
+type quotaRow struct {
+	Resource string `json:"resource"`
+	Used     int    `json:"used"`
+	Limit    int    `json:"limit"` // -1 for unlimited
+	Free     int    `json:"free"`  // -1 for unlimited
+}
+
+var quotaShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show used, limit and free of the project's quotas",
+	Long: `Show the compute (Nova limits), volume (Cinder) and network (Neutron)
+quotas of the current project, or of --project for admins, in one table.
+RAM is in MB and gigabytes in GB.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+		storageURL, err := validateTokenEndpoint(tok, "volumev3")
+		if err != nil {
+			return err
+		}
+		networkURL, err := validateTokenEndpoint(tok, "network")
+		if err != nil {
+			return err
+		}
+
+		// Nova limits are of the token's project unless one is asked for
+		projectID := currentProjectID()
+		limitsProject := ""
+		if flagQuotaProject != "" {
+			identityURL, err := validateTokenEndpoint(tok, "identity")
+			if err != nil {
+				return err
+			}
+			project, err := findProject(identityURL, flagQuotaProject, flagQuotaDomain)
+			if err != nil {
+				return err
+			}
+			projectID = project.ID
+			limitsProject = projectID
+		}
+
+		compute, err := api.GetComputeLimits(computeURL, tok.Value, limitsProject)
+		if err != nil {
+			return err
+		}
+		volume, err := api.GetVolumeQuotaUsage(storageURL, tok.Value, projectID)
+		if err != nil {
+			return err
+		}
+		network, err := api.GetNetworkQuotaUsage(networkURL, tok.Value, projectID)
+		if err != nil {
+			return err
+		}
+
+		var rows []quotaRow
+		for _, r := range []struct {
+			name   string
+			quotas map[string]api.QuotaUsage
+			key    string
+		}{
+			{"instances", compute, "instances"},
+			{"cores", compute, "cores"},
+			{"ram", compute, "ram"},
+			{"volumes", volume, "volumes"},
+			{"gigabytes", volume, "gigabytes"},
+			{"ports", network, "port"},
+			{"floating_ips", network, "floatingip"},
+		} {
+			q, ok := r.quotas[r.key]
+			if !ok {
+				continue
+			}
+			rows = append(rows, quotaRow{Resource: r.name, Used: q.InUse + q.Reserved, Limit: q.Limit, Free: q.Free()})
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(rows, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+
+		var quotaList []responseparser.Quota
+		for _, r := range rows {
+			quotaList = append(quotaList, responseparser.Quota{Resource: r.Resource, Used: r.Used, Limit: r.Limit, Free: r.Free})
+		}
+		responseparser.PrintQuotaTable(quotaList)
+		return nil
+	},
+}
+
+var quotaSetCmd = &cobra.Command{
+	Use:   "set <project> <resource=value>...",
+	Short: "Set a project's quotas [Req: admin]",
+	Long: `Set compute, volume and network quotas of a project in one command, with
+the same resource=value pairs as 'project create --quota'; -1 is unlimited.
+The project is a project ID, or a name that has to match exactly one
+project, in --domain if given.
+
+Example:
+  vhicmd quota set acme cores=64 ram=128G gigabytes=4000 volumes_ssd=20 floating_ips=5`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		compute, volume, network, err := parseQuotaFlags(args[1:])
+		if err != nil {
+			return err
+		}
+		identityURL, err := validateTokenEndpoint(tok, "identity")
+		if err != nil {
+			return err
+		}
+		project, err := findProject(identityURL, args[0], flagQuotaDomain)
+		if err != nil {
+			return err
+		}
+		return setProjectQuotas(project.ID, compute, volume, network)
+	},
+}
+
+// quotaShortage is the error of a quota check that found the project
+// over quota, as opposed to one that couldn't read the quota
+type quotaShortage []string
+
+func (q quotaShortage) Error() string {
+	return strings.Join(q, "; ")
+}
+
+// quotaShortages() describes each resource in order that has less free
+// than need asks for, as a quotaShortage error if any has
+func quotaShortages(quotas map[string]api.QuotaUsage, need map[string]int64, order []string) error {
+	var short quotaShortage
+	for _, resource := range order {
+		quota, ok := quotas[resource]
+		amount, needed := need[resource]
+		if !ok || !needed || amount <= 0 || quota.Free() < 0 {
+			continue
+		}
+		if int64(quota.Free()) < amount {
+			short = append(short, fmt.Sprintf("%s needs %d, %d free of %d", resource, amount, quota.Free(), quota.Limit))
+		}
+	}
+	if len(short) > 0 {
+		return short
+	}
+	return nil
+}
+
+// checkComputeQuota() fails if the project has no room for instances
+// more VMs of flavorRef
+func checkComputeQuota(computeURL, flavorRef string, instances int) error {
+	flavor, err := api.GetFlavorDetails(computeURL, tok.Value, flavorRef)
+	if err != nil {
+		return err
+	}
+	quotas, err := api.GetComputeLimits(computeURL, tok.Value, "")
+	if err != nil {
+		return err
+	}
+
+	need := map[string]int64{
+		"instances": int64(instances),
+		"cores":     int64(instances * flavor.Flavor.VCPUs),
+		"ram":       int64(instances * flavor.Flavor.RAM),
+	}
+	return quotaShortages(quotas, need, []string{"instances", "cores", "ram"})
+}
+
+// checkVolumeQuota() fails if the project has no room for count volumes
+// of sizeGB in total, overall or for volumeType
+func checkVolumeQuota(storageURL, volumeType string, sizeGB int64, count int) error {
+	quotas, err := api.GetVolumeQuotaUsage(storageURL, tok.Value, currentProjectID())
+	if err != nil {
+		return err
+	}
+
+	need := map[string]int64{"volumes": int64(count), "gigabytes": sizeGB}
+	order := []string{"volumes", "gigabytes"}
+	if volumeType != "" {
+		need["volumes_"+volumeType] = int64(count)
+		need["gigabytes_"+volumeType] = sizeGB
+		order = append(order, "volumes_"+volumeType, "gigabytes_"+volumeType)
+	}
+	return quotaShortages(quotas, need, order)
+}
+
+// checkPortQuota() fails if the project has no room for count ports
+func checkPortQuota(networkURL string, count int) error {
+	quotas, err := api.GetNetworkQuotaUsage(networkURL, tok.Value, currentProjectID())
+	if err != nil {
+		return err
+	}
+	return quotaShortages(quotas, map[string]int64{"port": int64(count)}, []string{"port"})
+}
+
+// checkVMQuota() fails before anything is created if a new VM of
+// flavorRef with volumes new volumes of gigabytes GB in total and ports
+// ports would exceed the project's quota, listing every shortage. A
+// quota that can't be read is only warned about, as the services
+// enforce it anyway.
+func checkVMQuota(flavorRef string, volumes int, gigabytes int64, volumeType string, ports int) error {
+	computeURL, err := validateTokenEndpoint(tok, "compute")
+	if err != nil {
+		return err
+	}
+	storageURL, err := validateTokenEndpoint(tok, "volumev3")
+	if err != nil {
+		return err
+	}
+	networkURL, err := validateTokenEndpoint(tok, "network")
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	check := func(service string, err error) {
+		var short quotaShortage
+		switch {
+		case errors.As(err, &short):
+			problems = append(problems, short...)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Warning: couldn't check the %s quota: %v\n", service, err)
+		}
+	}
+	check("compute", checkComputeQuota(computeURL, flavorRef, 1))
+	if volumes > 0 {
+		check("volume", checkVolumeQuota(storageURL, volumeType, gigabytes, volumes))
+	}
+	if ports > 0 {
+		check("port", checkPortQuota(networkURL, ports))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("not enough quota: %s (see 'vhicmd quota show')", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+var (
+	flagQuotaProject string
+	flagQuotaDomain  string
+)
+
+func init() {
+	quotaShowCmd.Flags().StringVar(&flagQuotaProject, "project", "", "Project name or ID (admin; default: the current project)")
+	quotaShowCmd.Flags().StringVar(&flagQuotaDomain, "domain", "", "Domain to look the --project name up in")
+	quotaShowCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+	quotaSetCmd.Flags().StringVar(&flagQuotaDomain, "domain", "", "Domain to look the project name up in")
+
+	quotaCmd.AddCommand(quotaShowCmd)
+	quotaCmd.AddCommand(quotaSetCmd)
+	rootCmd.AddCommand(quotaCmd)
+}
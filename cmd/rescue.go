@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/spf13/cobra"
+)
+
+var rescueCmd = &cobra.Command{
+	Use:   "rescue <vm>",
+	Short: "Boot a VM into rescue mode",
+	Long: `Boots the VM from a rescue image with its original disks attached
+as secondary devices, so a broken guest can be repaired.
+Without --image the VM's own image is used, which only works for VMs booted
+from an image. Volume-backed VMs, the usual kind on VHI, have none and need
+--image: a rescue image with the hw_rescue_device and hw_rescue_bus
+properties set, e.g. hw_rescue_device=cdrom hw_rescue_bus=sata.
+Run 'vhicmd unrescue <vm>' to return it to normal operation.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vmID := args[0]
+
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		id, err := api.GetVMIDByName(computeURL, tok.Value, vmID)
+		if err == nil {
+			vmID = id
+		}
+
+		vm, err := api.GetVMDetails(computeURL, tok.Value, vmID)
+		if err != nil {
+			return err
+		}
+		volumeBacked := vm.Image.ID == ""
+
+		imageRef := flagRescueImage
+		if imageRef == "" && volumeBacked {
+			return fmt.Errorf("VM %s boots from a volume and has no image to rescue with; give --image with a rescue image that has hw_rescue_device and hw_rescue_bus set", vm.Name)
+		}
+		if imageRef != "" {
+			imageURL, err := validateTokenEndpoint(tok, "image")
+			if err != nil {
+				return err
+			}
+			imgID, err := api.GetImageIDByName(imageURL, tok.Value, imageRef)
+			if err == nil {
+				imageRef = imgID
+			}
+			if volumeBacked {
+				img, err := api.GetImageDetails(imageURL, tok.Value, imageRef)
+				if err != nil {
+					return err
+				}
+				if img.RescueDevice == "" || img.RescueBus == "" {
+					return fmt.Errorf("image %s lacks hw_rescue_device or hw_rescue_bus, which rescuing a volume-backed VM needs; set them with 'vhicmd image set %s hw_rescue_device=cdrom hw_rescue_bus=sata'", img.Name, img.ID)
+				}
+			}
+		}
+
+		adminPass, err := api.RescueVM(computeURL, tok.Value, vmID, imageRef)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Waiting for VM %s to enter rescue mode...\n", vmID)
+		if _, err := api.WaitForStatus(computeURL, tok.Value, vmID, "RESCUE"); err != nil {
+			return err
+		}
+
+		fmt.Printf("VM %s is in rescue mode\n", vmID)
+		if adminPass != "" {
+			fmt.Printf("Rescue admin password: %s\n", adminPass)
+		}
+		consoleURL := fmt.Sprintf("%s:8800/compute/servers/instances/%s/console", tok.Host, vmID)
+		fmt.Printf("VHI console: %s\n", consoleURL)
+		return nil
+	},
+}
+
+var unrescueCmd = &cobra.Command{
+	Use:   "unrescue <vm>",
+	Short: "Return a VM from rescue mode",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vmID := args[0]
+
+		computeURL, err := validateTokenEndpoint(tok, "compute")
+		if err != nil {
+			return err
+		}
+
+		id, err := api.GetVMIDByName(computeURL, tok.Value, vmID)
+		if err == nil {
+			vmID = id
+		}
+
+		if err := api.UnrescueVM(computeURL, tok.Value, vmID); err != nil {
+			return err
+		}
+
+		fmt.Printf("Waiting for VM %s to become ACTIVE...\n", vmID)
+		if _, err := api.WaitForStatus(computeURL, tok.Value, vmID, "ACTIVE"); err != nil {
+			return err
+		}
+
+		fmt.Printf("VM %s returned from rescue mode\n", vmID)
+		return nil
+	},
+}
+
+var flagRescueImage string
+
+func init() {
+	rescueCmd.Flags().StringVar(&flagRescueImage, "image", "", "Rescue image name or ID; required for volume-backed VMs (default: the VM's own image)")
+
+	rootCmd.AddCommand(rescueCmd)
+	rootCmd.AddCommand(unrescueCmd)
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/responseparser"
+	"github.com/spf13/cobra"
+)
+
+var roleCmd = &cobra.Command{
+	Use:   "role",
+	Short: "List, assign and revoke Keystone roles [Req: admin]",
+}
+
+var roleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List roles, or role assignments",
+	Long: `Without flags, list the roles that can be assigned. With --user, --project
+or --domain, list the role assignments matching all of them; --effective
+expands group memberships and inherited roles into the users they apply to.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		identityURL, err := validateTokenEndpoint(tok, "identity")
+		if err != nil {
+			return err
+		}
+
+		if flagRoleUser == "" && flagRoleProject == "" && flagRoleDomain == "" {
+			roles, err := api.ListRoles(identityURL, tok.Value)
+			if err != nil {
+				return err
+			}
+			sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+
+			if flagJsonOutput {
+				b, _ := json.MarshalIndent(roles, "", "  ")
+				fmt.Println(string(b))
+				return nil
+			}
+
+			var roleList []responseparser.Role
+			for _, r := range roles {
+				roleList = append(roleList, responseparser.Role{ID: r.ID, Name: r.Name, DomainID: r.DomainID})
+			}
+			responseparser.PrintRolesTable(roleList)
+			return nil
+		}
+
+		query := map[string]string{"include_names": "true"}
+		if flagRoleUser != "" {
+			userID, err := resolveRoleUser(identityURL)
+			if err != nil {
+				return err
+			}
+			query["user.id"] = userID
+		}
+		if flagRoleProject != "" {
+			project, err := findProject(identityURL, flagRoleProject, flagRoleProjectDomain)
+			if err != nil {
+				return err
+			}
+			query["scope.project.id"] = project.ID
+		}
+		if flagRoleDomain != "" {
+			query["scope.domain.id"] = resolveDomainID(identityURL, flagRoleDomain)
+		}
+		if flagRoleEffective {
+			query["effective"] = "true"
+		}
+
+		assignments, err := api.ListRoleAssignments(identityURL, tok.Value, query)
+		if err != nil {
+			return err
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(assignments, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+
+		var assignmentList []responseparser.RoleAssignment
+		for _, a := range assignments {
+			row := responseparser.RoleAssignment{Role: firstNonEmpty(a.Role.Name, a.Role.ID)}
+			switch {
+			case a.User != nil:
+				row.Actor = "user:" + assignmentName(a.User)
+			case a.Group != nil:
+				row.Actor = "group:" + assignmentName(a.Group)
+			}
+			switch {
+			case a.Scope.Project != nil:
+				row.Scope = "project:" + assignmentName(a.Scope.Project)
+			case a.Scope.Domain != nil:
+				row.Scope = "domain:" + assignmentName(a.Scope.Domain)
+			default:
+				row.Scope = "system"
+			}
+			assignmentList = append(assignmentList, row)
+		}
+		sort.Slice(assignmentList, func(i, j int) bool {
+			a, b := assignmentList[i], assignmentList[j]
+			if a.Scope != b.Scope {
+				return a.Scope < b.Scope
+			}
+			if a.Actor != b.Actor {
+				return a.Actor < b.Actor
+			}
+			return a.Role < b.Role
+		})
+		responseparser.PrintRoleAssignmentsTable(assignmentList)
+		return nil
+	},
+}
+
+var roleAssignCmd = &cobra.Command{
+	Use:   "assign <role>",
+	Short: "Grant a user a role on a project or domain",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return grantRole(args[0], false)
+	},
+}
+
+var roleRevokeCmd = &cobra.Command{
+	Use:   "revoke <role>",
+	Short: "Remove a user's role on a project or domain",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return grantRole(args[0], true)
+	},
+}
+
+// grantRole() assigns role to --user on --project or --domain, or
+// revokes it
+func grantRole(role string, revoke bool) error {
+	identityURL, err := validateTokenEndpoint(tok, "identity")
+	if err != nil {
+		return err
+	}
+
+	userID, err := resolveRoleUser(identityURL)
+	if err != nil {
+		return err
+	}
+	roleID, err := resolveRoleID(identityURL, role)
+	if err != nil {
+		return err
+	}
+
+	scope, scopeID := "domain", ""
+	grant, remove := api.AssignDomainRole, api.RevokeDomainRole
+	if flagRoleProject != "" {
+		project, err := findProject(identityURL, flagRoleProject, flagRoleProjectDomain)
+		if err != nil {
+			return err
+		}
+		scope, scopeID = "project", project.ID
+		grant, remove = api.AssignProjectRole, api.RevokeProjectRole
+	} else {
+		scopeID = resolveDomainID(identityURL, flagRoleDomain)
+	}
+
+	if revoke {
+		if err := remove(identityURL, tok.Value, scopeID, userID, roleID); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked role %s of user %s on %s %s\n", role, flagRoleUser, scope, firstNonEmpty(flagRoleProject, flagRoleDomain))
+		return nil
+	}
+	if err := grant(identityURL, tok.Value, scopeID, userID, roleID); err != nil {
+		return err
+	}
+	fmt.Printf("Granted role %s to user %s on %s %s\n", role, flagRoleUser, scope, firstNonEmpty(flagRoleProject, flagRoleDomain))
+	return nil
+}
+
+// resolveRoleID() returns the ID of the role named or with ID nameOrID
+func resolveRoleID(identityURL, nameOrID string) (string, error) {
+	roles, err := api.ListRoles(identityURL, tok.Value)
+	if err != nil {
+		return "", err
+	}
+	for _, r := range roles {
+		if r.ID == nameOrID || r.Name == nameOrID {
+			return r.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no role found for %s", nameOrID)
+}
+
+// resolveRoleUser() returns the ID of --user, looked up in --user-domain
+// if given
+func resolveRoleUser(identityURL string) (string, error) {
+	domainID := ""
+	if flagRoleUserDomain != "" {
+		domainID = resolveDomainID(identityURL, flagRoleUserDomain)
+	}
+	return resolveUserID(identityURL, flagRoleUser, domainID)
+}
+
+// assignmentName() returns the name of a role assignment's user, group,
+// project or domain, or its ID without include_names
+func assignmentName(actor *api.AssignmentActor) string {
+	return firstNonEmpty(actor.Name, actor.ID)
+}
+
+var (
+	flagRoleUser          string
+	flagRoleUserDomain    string
+	flagRoleProject       string
+	flagRoleProjectDomain string
+	flagRoleDomain        string
+	flagRoleEffective     bool
+)
+
+func init() {
+	roleListCmd.Flags().StringVar(&flagRoleUser, "user", "", "Only assignments of this user (name or ID)")
+	roleListCmd.Flags().StringVar(&flagRoleUserDomain, "user-domain", "", "Domain to look the --user name up in")
+	roleListCmd.Flags().StringVar(&flagRoleProject, "project", "", "Only assignments on this project (name or ID)")
+	roleListCmd.Flags().StringVar(&flagRoleProjectDomain, "project-domain", "", "Domain to look the --project name up in")
+	roleListCmd.Flags().StringVar(&flagRoleDomain, "domain", "", "Only assignments on this domain (name or ID)")
+	roleListCmd.Flags().BoolVar(&flagRoleEffective, "effective", false, "Expand group and inherited assignments")
+	roleListCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+	roleListCmd.MarkFlagsMutuallyExclusive("project", "domain")
+
+	for _, c := range []*cobra.Command{roleAssignCmd, roleRevokeCmd} {
+		c.Flags().StringVar(&flagRoleUser, "user", "", "User name or ID")
+		c.Flags().StringVar(&flagRoleUserDomain, "user-domain", "", "Domain to look the --user name up in")
+		c.Flags().StringVar(&flagRoleProject, "project", "", "Project name or ID")
+		c.Flags().StringVar(&flagRoleProjectDomain, "project-domain", "", "Domain to look the --project name up in")
+		c.Flags().StringVar(&flagRoleDomain, "domain", "", "Domain name or ID")
+		c.MarkFlagRequired("user")
+		c.MarkFlagsOneRequired("project", "domain")
+		c.MarkFlagsMutuallyExclusive("project", "domain")
+	}
+
+	roleCmd.AddCommand(roleListCmd)
+	roleCmd.AddCommand(roleAssignCmd)
+	roleCmd.AddCommand(roleRevokeCmd)
+	rootCmd.AddCommand(roleCmd)
+}
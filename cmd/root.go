@@ -61,6 +61,12 @@ func init() {
 		if cmd.Name() == "auth" || (cmd.Parent() != nil && cmd.Parent().Name() == "config") {
 			return nil
 		}
+		// auth subcommands also work with an expired token
+		if cmd.Parent() != nil && cmd.Parent().Name() == "auth" {
+			tok, _ = api.LoadSavedToken(host)
+			tok.Host = host
+			return nil
+		}
 
 		var err error
 		tok, err = api.LoadTokenStruct(host)
@@ -70,6 +76,7 @@ func init() {
 			}
 			return fmt.Errorf("no valid auth token found on disk for host '%s'; run 'vhicmd auth' first", host)
 		}
+		warnExpiry(tok)
 
 		return nil
 	}
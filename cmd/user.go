@@ -0,0 +1,276 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/jessegalley/vhicmd/internal/responseparser"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage Keystone users [Req: admin]",
+}
+
+// userRow is a user of 'user list', with its roles on --project
+type userRow struct {
+	api.UserDetail
+	Roles []string `json:"roles,omitempty"`
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users of a domain or project",
+	Long: `List users, of --domain only if given. With --project only users with a
+role on the project are listed, with their roles, including those granted
+through groups.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		identityURL, err := validateTokenEndpoint(tok, "identity")
+		if err != nil {
+			return err
+		}
+
+		query := map[string]string{}
+		if flagUserDomain != "" {
+			query["domain_id"] = resolveDomainID(identityURL, flagUserDomain)
+		}
+		users, err := api.ListUsers(identityURL, tok.Value, query)
+		if err != nil {
+			return err
+		}
+
+		var rows []userRow
+		if flagUserProject != "" {
+			project, err := findProject(identityURL, flagUserProject, flagUserProjectDomain)
+			if err != nil {
+				return err
+			}
+			assignments, err := api.ListRoleAssignments(identityURL, tok.Value, map[string]string{
+				"scope.project.id": project.ID,
+				"effective":        "true",
+				"include_names":    "true",
+			})
+			if err != nil {
+				return err
+			}
+			roles := map[string][]string{}
+			for _, a := range assignments {
+				if a.User != nil && !slices.Contains(roles[a.User.ID], a.Role.Name) {
+					roles[a.User.ID] = append(roles[a.User.ID], a.Role.Name)
+				}
+			}
+			for _, u := range users {
+				if userRoles, ok := roles[u.ID]; ok {
+					sort.Strings(userRoles)
+					rows = append(rows, userRow{UserDetail: u, Roles: userRoles})
+				}
+			}
+		} else {
+			for _, u := range users {
+				rows = append(rows, userRow{UserDetail: u})
+			}
+		}
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(rows, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+
+		var userList []responseparser.User
+		for _, u := range rows {
+			userList = append(userList, responseparser.User{
+				ID:              u.ID,
+				Name:            u.Name,
+				DomainID:        u.DomainID,
+				Enabled:         u.Enabled,
+				PasswordExpires: u.PasswordExpiresAt,
+				Roles:           u.Roles,
+			})
+		}
+		responseparser.PrintUsersTable(userList)
+		return nil
+	},
+}
+
+var userCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a user",
+	Long: `Create a user in --domain (default: 'domain' from config). The password
+is prompted for unless --password is given. With --project the project
+becomes the user's default and they're granted --role (default member) on it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		identityURL, err := validateTokenEndpoint(tok, "identity")
+		if err != nil {
+			return err
+		}
+
+		domain := flagUserDomain
+		if domain == "" {
+			domain = viper.GetString("domain")
+		}
+		if domain == "" {
+			return fmt.Errorf("no domain specified; provide --domain or set 'domain' in config")
+		}
+
+		password := flagUserPassword
+		if password == "" {
+			if password, err = readNewPassword(); err != nil {
+				return err
+			}
+		}
+
+		req := api.CreateUserRequest{
+			Name:        args[0],
+			DomainID:    resolveDomainID(identityURL, domain),
+			Password:    password,
+			Description: flagUserDescription,
+			Email:       flagUserEmail,
+			Enabled:     true,
+		}
+		var roleIDs []string
+		if flagUserProject != "" {
+			project, err := findProject(identityURL, flagUserProject, firstNonEmpty(flagUserProjectDomain, domain))
+			if err != nil {
+				return err
+			}
+			req.DefaultProjectID = project.ID
+			for _, role := range flagUserRoles {
+				id, err := resolveRoleID(identityURL, role)
+				if err != nil {
+					return err
+				}
+				roleIDs = append(roleIDs, id)
+			}
+		}
+
+		user, err := api.CreateUser(identityURL, tok.Value, req)
+		if err != nil {
+			return err
+		}
+		for i, roleID := range roleIDs {
+			if err := api.AssignProjectRole(identityURL, tok.Value, req.DefaultProjectID, user.ID, roleID); err != nil {
+				return err
+			}
+			if !flagJsonOutput {
+				fmt.Printf("Granted role %s on project %s\n", flagUserRoles[i], flagUserProject)
+			}
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(user, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+		fmt.Printf("User %s created: %s\n", user.Name, user.ID)
+		return nil
+	},
+}
+
+var userDisableCmd = &cobra.Command{
+	Use:   "disable <user>",
+	Short: "Disable a user; their tokens stop working",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateUser(args[0], map[string]interface{}{"enabled": false}, "User %s disabled\n")
+	},
+}
+
+var userEnableCmd = &cobra.Command{
+	Use:   "enable <user>",
+	Short: "Enable a disabled user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateUser(args[0], map[string]interface{}{"enabled": true}, "User %s enabled\n")
+	},
+}
+
+var userSetPasswordCmd = &cobra.Command{
+	Use:   "set-password <user>",
+	Short: "Reset a user's password",
+	Long: `Set a user's password as an admin, without their current one. The
+password is prompted for unless --password is given. To change your own
+password use 'vhicmd auth change-password'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		password := flagUserPassword
+		if password == "" {
+			var err error
+			if password, err = readNewPassword(); err != nil {
+				return err
+			}
+		}
+		return updateUser(args[0], map[string]interface{}{"password": password}, "Password of user %s set\n")
+	},
+}
+
+// updateUser() changes attrs of the user nameOrID, looked up in --domain
+// if given, and prints done with its name
+func updateUser(nameOrID string, attrs map[string]interface{}, done string) error {
+	identityURL, err := validateTokenEndpoint(tok, "identity")
+	if err != nil {
+		return err
+	}
+
+	domainID := ""
+	if flagUserDomain != "" {
+		domainID = resolveDomainID(identityURL, flagUserDomain)
+	}
+	userID, err := resolveUserID(identityURL, nameOrID, domainID)
+	if err != nil {
+		return err
+	}
+
+	user, err := api.UpdateUser(identityURL, tok.Value, userID, attrs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf(done, user.Name)
+	return nil
+}
+
+var (
+	flagUserDomain        string
+	flagUserProject       string
+	flagUserProjectDomain string
+	flagUserPassword      string
+	flagUserEmail         string
+	flagUserDescription   string
+	flagUserRoles         []string
+)
+
+func init() {
+	userListCmd.Flags().StringVar(&flagUserDomain, "domain", "", "Only users of this domain (name or ID)")
+	userListCmd.Flags().StringVar(&flagUserProject, "project", "", "Only users with a role on this project (name or ID)")
+	userListCmd.Flags().StringVar(&flagUserProjectDomain, "project-domain", "", "Domain to look the --project name up in")
+	userListCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+
+	userCreateCmd.Flags().StringVar(&flagUserDomain, "domain", "", "Domain name or ID (default: 'domain' from config)")
+	userCreateCmd.Flags().StringVar(&flagUserPassword, "password", "", "Password (prompted for if not given)")
+	userCreateCmd.Flags().StringVar(&flagUserEmail, "email", "", "Email address")
+	userCreateCmd.Flags().StringVar(&flagUserDescription, "description", "", "User description")
+	userCreateCmd.Flags().StringVar(&flagUserProject, "project", "", "Default project, on which --role is granted")
+	userCreateCmd.Flags().StringVar(&flagUserProjectDomain, "project-domain", "", "Domain to look the --project name up in (default: --domain)")
+	userCreateCmd.Flags().StringArrayVar(&flagUserRoles, "role", []string{"member"}, "Role to grant on --project, repeatable")
+	userCreateCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+
+	for _, c := range []*cobra.Command{userDisableCmd, userEnableCmd, userSetPasswordCmd} {
+		c.Flags().StringVar(&flagUserDomain, "domain", "", "Domain to look the user name up in")
+	}
+	userSetPasswordCmd.Flags().StringVar(&flagUserPassword, "password", "", "New password (prompted for if not given)")
+
+	userCmd.AddCommand(userListCmd)
+	userCmd.AddCommand(userCreateCmd)
+	userCmd.AddCommand(userDisableCmd)
+	userCmd.AddCommand(userEnableCmd)
+	userCmd.AddCommand(userSetPasswordCmd)
+	rootCmd.AddCommand(userCmd)
+}
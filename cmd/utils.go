@@ -8,14 +8,12 @@ import (
 	"log"
 	"net"
 	"os"
-	"path/filepath"
-	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"syscall"
 
-	"github.com/facette/natsort"
 	"github.com/jessegalley/vhicmd/api"
+	"github.com/spf13/viper"
 	"golang.org/x/term"
 )
 
@@ -49,6 +47,31 @@ func readPasswordFromStdin() (string, error) {
 	return password, nil
 }
 
+// readNewPassword() prompts twice for a new password without echoing
+// it, and fails if the two don't match or it's empty
+func readNewPassword() (string, error) {
+	fmt.Print("new password: ")
+	first, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+	fmt.Print("repeat new password: ")
+	second, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+
+	if string(first) != string(second) {
+		return "", fmt.Errorf("passwords don't match")
+	}
+	if len(first) == 0 {
+		return "", fmt.Errorf("empty password")
+	}
+	return string(first), nil
+}
+
 // readConfirmation() prompts the user for a yes/no confirmation
 // on stdout and then reads in and returns their response
 // returns true for yes, false for no
@@ -158,75 +181,90 @@ func validateMacAddr(mac string) error {
 	return nil
 }
 
-// findVMDKsParallel launches one goroutine per datastore in /mnt/vmdk
-func findVMDKsParallel(pattern string) ([]string, error) {
-	rootDir := "/mnt/vmdk"
-	var matches []string
-	var wg sync.WaitGroup
-	var mu sync.Mutex
+// validateDiskBus() checks the given disk bus is one Nova accepts for
+// block devices, an empty bus leaves the choice to Nova
+func validateDiskBus(bus string) error {
+	switch bus {
+	case "", "sata", "scsi", "virtio", "ide":
+		return nil
+	}
+	return fmt.Errorf("disk bus must be one of: sata, scsi, virtio, ide")
+}
 
-	entries, err := os.ReadDir(rootDir) // Get all top-level directories (datastores)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read directory %s: %v", rootDir, err)
-	}
-
-	// Channel for collecting results
-	results := make(chan string, 1000) // Buffered to prevent blocking
-
-	// Start searching each datastore in its own goroutine
-	for _, entry := range entries {
-		if entry.IsDir() {
-			wg.Add(1)
-			go func(datastore string) {
-				defer wg.Done()
-				storePath := filepath.Join(rootDir, datastore)
-				storeMatches := findVMDKsInPath(storePath, pattern)
-
-				// Lock before appending to shared slice
-				mu.Lock()
-				matches = append(matches, storeMatches...)
-				mu.Unlock()
-
-				// Send matches to channel (optional)
-				for _, match := range storeMatches {
-					results <- match
-				}
-			}(entry.Name())
+// resolveDiskBus() picks the disk bus for a boot volume: the flag value,
+// then 'disk_bus' from config, then the image's hw_disk_bus property.
+// Returns an empty string if none are set so Nova uses its default.
+func resolveDiskBus(flagValue, imageURL, imageID string) (string, error) {
+	bus := flagValue
+	if bus == "" {
+		bus = viper.GetString("disk_bus")
+	}
+	if bus == "" && imageID != "" {
+		img, err := api.GetImageByID(imageURL, tok.Value, imageID)
+		if err == nil {
+			bus = img.DiskBus
 		}
 	}
+	if err := validateDiskBus(bus); err != nil {
+		return "", err
+	}
+	return bus, nil
+}
 
-	// Close channel when all goroutines are done
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	wg.Wait()
-
-	// Sort matches using natural sorting
-	sort.Slice(matches, func(i, j int) bool {
-		return natsort.Compare(strings.ToLower(matches[i]), strings.ToLower(matches[j]))
-	})
+// parseByteSize() parses sizes like "512", "50K", "100M", "8G" or "1T"
+// (binary units, optional trailing B) into bytes
+func parseByteSize(s string) (int64, error) {
+	str := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	multiplier := int64(1)
+	if str != "" {
+		switch str[len(str)-1] {
+		case 'K':
+			multiplier = 1024
+		case 'M':
+			multiplier = 1024 * 1024
+		case 'G':
+			multiplier = 1024 * 1024 * 1024
+		case 'T':
+			multiplier = 1024 * 1024 * 1024 * 1024
+		}
+		if multiplier > 1 {
+			str = str[:len(str)-1]
+		}
+	}
 
-	return matches, nil
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q; use a number with an optional K, M, G or T suffix", s)
+	}
+	return int64(value * float64(multiplier)), nil
 }
 
-// findVMDKsInPath recursively searches a specific datastore path
-func findVMDKsInPath(rootPath, pattern string) []string {
-	var matches []string
-	filepath.WalkDir(rootPath, func(path string, d os.DirEntry, err error) error {
+// uploadOptionsFromFlags() builds image upload options from the
+// --bwlimit, --retries and --stage flag values
+func uploadOptionsFromFlags(bwLimit string, retries int, stage bool) (api.UploadOptions, error) {
+	opts := api.UploadOptions{Retries: retries, UseStage: stage}
+	if bwLimit != "" {
+		limit, err := parseByteSize(bwLimit)
 		if err != nil {
-			fmt.Printf("Warning: Cannot access %s: %v\n", path, err)
-			return nil
+			return opts, fmt.Errorf("invalid --bwlimit: %v", err)
 		}
+		opts.BwLimit = limit
+	}
+	return opts, nil
+}
 
-		if !d.IsDir() && strings.HasSuffix(d.Name(), "-flat.vmdk") {
-			baseName := strings.TrimSuffix(d.Name(), "-flat.vmdk")
-			if strings.Contains(strings.ToLower(baseName), strings.ToLower(pattern)) {
-				matches = append(matches, path)
-			}
-		}
-		return nil
-	})
-	return matches
+// formatByteSize() formats bytes with the binary units parseByteSize
+// accepts, e.g. "512M" or "40G"
+func formatByteSize(n int64) string {
+	units := []string{"", "K", "M", "G", "T"}
+	value := float64(n)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if value == float64(int64(value)) {
+		return fmt.Sprintf("%d%s", int64(value), units[i])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[i])
 }
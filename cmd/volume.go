@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/jessegalley/vhicmd/api"
+	"github.com/spf13/cobra"
+)
+
+var volumeCmd = &cobra.Command{
+	Use:   "volume",
+	Short: "Manage storage volumes (extend, retype, clone, transfer)",
+}
+
+var volumeExtendCmd = &cobra.Command{
+	Use:   "extend <volume>",
+	Short: "Extend a volume to a larger size",
+	Long: `Extend a volume to the size given by --size (GB).
+Attached (in-use) volumes are extended online; the guest may need
+to rescan the disk and grow its filesystem afterwards.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageURL, err := validateTokenEndpoint(tok, "volumev3")
+		if err != nil {
+			return err
+		}
+
+		volumeID := resolveVolumeID(storageURL, args[0])
+
+		vol, err := api.GetVolumeDetails(storageURL, tok.Value, volumeID)
+		if err != nil {
+			return err
+		}
+
+		if volumeFlagSize <= vol.Size {
+			return fmt.Errorf("new size %d GB must be larger than current size %d GB", volumeFlagSize, vol.Size)
+		}
+		if vol.Status != "available" && vol.Status != "in-use" {
+			return fmt.Errorf("volume %s is %s; must be available or in-use to extend", volumeID, vol.Status)
+		}
+		if vol.Status == "in-use" {
+			fmt.Printf("Volume %s is attached, extending online...\n", volumeID)
+		}
+
+		if err := api.ExtendVolume(storageURL, tok.Value, volumeID, volumeFlagSize); err != nil {
+			return err
+		}
+
+		fmt.Printf("Waiting for volume to become %s...\n", vol.Status)
+		if err := api.WaitForVolumeStatus(storageURL, tok.Value, volumeID, vol.Status); err != nil {
+			return fmt.Errorf("failed waiting for volume: %v", err)
+		}
+
+		fmt.Printf("Volume %s extended from %d GB to %d GB\n", volumeID, vol.Size, volumeFlagSize)
+		return nil
+	},
+}
+
+var volumeRetypeCmd = &cobra.Command{
+	Use:   "retype <volume>",
+	Short: "Change the volume type of a volume",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageURL, err := validateTokenEndpoint(tok, "volumev3")
+		if err != nil {
+			return err
+		}
+
+		if volumeFlagMigrationPolicy != "never" && volumeFlagMigrationPolicy != "on-demand" {
+			return fmt.Errorf("migration policy must be one of: never, on-demand")
+		}
+
+		volumeID := resolveVolumeID(storageURL, args[0])
+
+		vol, err := api.GetVolumeDetails(storageURL, tok.Value, volumeID)
+		if err != nil {
+			return err
+		}
+		if vol.VolumeType == volumeFlagType {
+			return fmt.Errorf("volume %s is already of type %s", volumeID, volumeFlagType)
+		}
+
+		if err := api.RetypeVolume(storageURL, tok.Value, volumeID, volumeFlagType, volumeFlagMigrationPolicy); err != nil {
+			return err
+		}
+
+		fmt.Printf("Waiting for volume to become %s...\n", vol.Status)
+		if err := api.WaitForVolumeStatus(storageURL, tok.Value, volumeID, vol.Status); err != nil {
+			return fmt.Errorf("failed waiting for volume: %v", err)
+		}
+
+		fmt.Printf("Volume %s retyped from %s to %s\n", volumeID, stringOrNone(vol.VolumeType), volumeFlagType)
+		return nil
+	},
+}
+
+var volumeCloneCmd = &cobra.Command{
+	Use:   "clone <volume>",
+	Short: "Create a new volume as a copy of an existing volume",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageURL, err := validateTokenEndpoint(tok, "volumev3")
+		if err != nil {
+			return err
+		}
+
+		volumeID := resolveVolumeID(storageURL, args[0])
+
+		src, err := api.GetVolumeDetails(storageURL, tok.Value, volumeID)
+		if err != nil {
+			return err
+		}
+
+		size := volumeFlagSize
+		if size == 0 {
+			size = src.Size
+		}
+		if size < src.Size {
+			return fmt.Errorf("clone size %d GB cannot be smaller than source size %d GB", size, src.Size)
+		}
+
+		name := volumeFlagName
+		if name == "" {
+			name = fmt.Sprintf("%s-clone", src.Name)
+		}
+
+		var request api.CreateVolumeRequest
+		request.Volume.Name = name
+		request.Volume.Size = size
+		request.Volume.Description = fmt.Sprintf("Clone of %s", volumeID)
+		request.Volume.VolumeType = volumeFlagType
+		request.Volume.SourceVolID = volumeID
+
+		resp, err := api.CreateVolume(storageURL, tok.Value, request)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Waiting for volume to become available...\n")
+		if err := api.WaitForVolumeStatus(storageURL, tok.Value, resp.Volume.ID, "available"); err != nil {
+			return fmt.Errorf("failed waiting for volume: %v", err)
+		}
+
+		fmt.Printf("Volume cloned: ID: %s, Name: %s, Size: %d GB\n", resp.Volume.ID, name, size)
+		return nil
+	},
+}
+
+var volumeTransferCmd = &cobra.Command{
+	Use:   "transfer",
+	Short: "Transfer volumes between projects",
+}
+
+var volumeTransferCreateCmd = &cobra.Command{
+	Use:   "create <volume>",
+	Short: "Offer a volume for transfer to another project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageURL, err := validateTokenEndpoint(tok, "volumev3")
+		if err != nil {
+			return err
+		}
+
+		volumeID := resolveVolumeID(storageURL, args[0])
+
+		resp, err := api.CreateVolumeTransfer(storageURL, tok.Value, volumeID, volumeFlagName)
+		if err != nil {
+			return err
+		}
+
+		if err := api.WaitForVolumeStatus(storageURL, tok.Value, volumeID, "awaiting-transfer"); err != nil {
+			return fmt.Errorf("failed waiting for volume: %v", err)
+		}
+
+		if flagJsonOutput {
+			b, _ := json.MarshalIndent(resp.Transfer, "", "  ")
+			fmt.Println(string(b))
+			return nil
+		}
+
+		fmt.Printf("Volume transfer created:\n")
+		fmt.Printf("  Transfer ID: %s\n", resp.Transfer.ID)
+		fmt.Printf("  Auth Key: %s\n", resp.Transfer.AuthKey)
+		fmt.Printf("  Volume: %s\n", resp.Transfer.VolumeID)
+		fmt.Printf("\nAccept from the target project with:\n")
+		fmt.Printf("  vhicmd volume transfer accept %s --auth-key %s\n", resp.Transfer.ID, resp.Transfer.AuthKey)
+		return nil
+	},
+}
+
+var volumeTransferAcceptCmd = &cobra.Command{
+	Use:   "accept <transfer_id>",
+	Short: "Accept a volume transfer into the current project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		storageURL, err := validateTokenEndpoint(tok, "volumev3")
+		if err != nil {
+			return err
+		}
+
+		resp, err := api.AcceptVolumeTransfer(storageURL, tok.Value, args[0], volumeFlagAuthKey)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Waiting for volume to become available...\n")
+		if err := api.WaitForVolumeStatus(storageURL, tok.Value, resp.Transfer.VolumeID, "available"); err != nil {
+			return fmt.Errorf("failed waiting for volume: %v", err)
+		}
+
+		fmt.Printf("Volume %s transferred to project %s\n", resp.Transfer.VolumeID, tok.Project)
+		return nil
+	},
+}
+
+// resolveVolumeID returns the ID of the volume matching nameOrID by name,
+// or nameOrID unchanged if no single volume matches.
+func resolveVolumeID(storageURL, nameOrID string) string {
+	id, err := api.GetVolumeIDByName(storageURL, tok.Value, nameOrID)
+	if err == nil {
+		return id
+	}
+	return nameOrID
+}
+
+var (
+	volumeFlagSize            int
+	volumeFlagName            string
+	volumeFlagType            string
+	volumeFlagMigrationPolicy string
+	volumeFlagAuthKey         string
+)
+
+func init() {
+	volumeExtendCmd.Flags().IntVar(&volumeFlagSize, "size", 0, "New size of the volume in GB")
+	volumeExtendCmd.MarkFlagRequired("size")
+
+	volumeRetypeCmd.Flags().StringVar(&volumeFlagType, "type", "", "New volume type")
+	volumeRetypeCmd.Flags().StringVar(&volumeFlagMigrationPolicy, "migration-policy", "never", "Migration policy: never, on-demand")
+	volumeRetypeCmd.MarkFlagRequired("type")
+
+	volumeCloneCmd.Flags().StringVar(&volumeFlagName, "name", "", "Name of the new volume (default: <source>-clone)")
+	volumeCloneCmd.Flags().IntVar(&volumeFlagSize, "size", 0, "Size of the new volume in GB (default: source size)")
+	volumeCloneCmd.Flags().StringVar(&volumeFlagType, "type", "", "Volume type of the new volume (default: source type)")
+
+	volumeTransferCreateCmd.Flags().StringVar(&volumeFlagName, "name", "", "Name of the transfer")
+	volumeTransferCreateCmd.Flags().BoolVar(&flagJsonOutput, "json", false, "Output in JSON format")
+	volumeTransferAcceptCmd.Flags().StringVar(&volumeFlagAuthKey, "auth-key", "", "Auth key returned by 'transfer create'")
+	volumeTransferAcceptCmd.MarkFlagRequired("auth-key")
+
+	volumeTransferCmd.AddCommand(volumeTransferCreateCmd)
+	volumeTransferCmd.AddCommand(volumeTransferAcceptCmd)
+
+	volumeCmd.AddCommand(volumeExtendCmd)
+	volumeCmd.AddCommand(volumeRetypeCmd)
+	volumeCmd.AddCommand(volumeCloneCmd)
+	volumeCmd.AddCommand(volumeTransferCmd)
+	rootCmd.AddCommand(volumeCmd)
+}
@@ -18,6 +18,18 @@ type Config struct {
 	Networks string `mapstructure:"networks"`
 	FlavorID string `mapstructure:"flavor_id"`
 	ImageID  string `mapstructure:"image_id"`
+
+	VolumeType string `mapstructure:"volume_type"`
+	DiskBus    string `mapstructure:"disk_bus"`
+	VMDKRoots  string `mapstructure:"vmdk_roots"`
+
+	VCenterHost     string `mapstructure:"vcenter_host"`
+	VCenterUsername string `mapstructure:"vcenter_username"`
+	VCenterPassword string `mapstructure:"vcenter_password"`
+	VCenterInsecure bool   `mapstructure:"vcenter_insecure"`
+
+	TokenWarnMinutes int `mapstructure:"token_warn_minutes"`
+	PasswordWarnDays int `mapstructure:"password_warn_days"`
 }
 
 func InitConfig(cfgFile string) (*viper.Viper, error) {
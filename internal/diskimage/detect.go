@@ -0,0 +1,194 @@
+package diskimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Info describes a disk image as detected from its content.
+type Info struct {
+	Format      string // qcow2, vmdk, vhd, vhdx, vdi, iso, raw (Glance disk_format names)
+	VirtualSize int64  // size of the disk as seen by the guest, in bytes
+	Detail      string // sub-type, e.g. "monolithicSparse" or "dynamic"
+	Backing     bool   // a QCOW2 overlay that needs its backing file
+}
+
+// vhdxMetadataRegion and vhdxVirtualDiskSize are the GUIDs (in on-disk byte
+// order) of the VHDX metadata region and its virtual disk size item.
+var (
+	vhdxMetadataRegion  = []byte{0x06, 0xa2, 0x7c, 0x8b, 0x90, 0x47, 0x9a, 0x4b, 0xb8, 0xfe, 0x57, 0x5f, 0x05, 0x0f, 0x88, 0x6e}
+	vhdxVirtualDiskSize = []byte{0x24, 0x42, 0xa5, 0x2f, 0x1b, 0xcd, 0x76, 0x48, 0xb2, 0x11, 0x5d, 0xbe, 0xd8, 0x3b, 0xf4, 0xb8}
+)
+
+// DetectFile opens path and detects its disk format.
+func DetectFile(path string) (Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	return Detect(f, st.Size())
+}
+
+// Detect identifies the disk format of r from its magic bytes, falling
+// back to raw when no known header is found. size is the file size.
+func Detect(r io.ReaderAt, size int64) (Info, error) {
+	head := make([]byte, 512)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return Info{}, fmt.Errorf("failed to read image header: %v", err)
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("QFI\xfb")):
+		return detectQCOW2(head)
+	case bytes.HasPrefix(head, []byte("KDMV")):
+		return detectVMDKSparse(head)
+	case bytes.HasPrefix(head, []byte("# Disk DescriptorFile")):
+		return Info{}, fmt.Errorf("file is a VMDK descriptor, not disk data; upload its extent (e.g. the -flat.vmdk) instead")
+	case bytes.HasPrefix(head, []byte("vhdxfile")):
+		return detectVHDX(r)
+	case bytes.HasPrefix(head, []byte("conectix")):
+		// dynamic and differencing VHDs keep a copy of the footer at the start
+		return detectVHD(head)
+	case len(head) >= 0x48 && binary.LittleEndian.Uint32(head[0x40:]) == 0xbeda107f:
+		return detectVDI(r)
+	}
+
+	if size >= 512 {
+		footer := make([]byte, 512)
+		if _, err := r.ReadAt(footer, size-512); err == nil && bytes.HasPrefix(footer, []byte("conectix")) {
+			return detectVHD(footer)
+		}
+	}
+
+	if size >= 0x8006 {
+		pvd := make([]byte, 5)
+		if _, err := r.ReadAt(pvd, 0x8001); err == nil && string(pvd) == "CD001" {
+			return Info{Format: "iso", VirtualSize: size, Detail: "ISO9660"}, nil
+		}
+	}
+
+	return Info{Format: "raw", VirtualSize: size}, nil
+}
+
+// detectQCOW2 reads the virtual size and backing file offset from a
+// QCOW2 header.
+func detectQCOW2(head []byte) (Info, error) {
+	if len(head) < 32 {
+		return Info{}, fmt.Errorf("truncated QCOW2 header")
+	}
+	version := binary.BigEndian.Uint32(head[4:])
+	info := Info{
+		Format:      "qcow2",
+		VirtualSize: int64(binary.BigEndian.Uint64(head[24:])),
+		Detail:      fmt.Sprintf("version %d", version),
+		Backing:     binary.BigEndian.Uint64(head[8:]) != 0,
+	}
+	if info.Backing {
+		info.Detail += ", backing file"
+	}
+	return info, nil
+}
+
+// detectVMDKSparse reads the capacity from a hosted sparse extent header.
+func detectVMDKSparse(head []byte) (Info, error) {
+	if len(head) < 20 {
+		return Info{}, fmt.Errorf("truncated VMDK sparse header")
+	}
+	sectors := binary.LittleEndian.Uint64(head[12:])
+	return Info{Format: "vmdk", VirtualSize: int64(sectors) * 512, Detail: "sparse"}, nil
+}
+
+// detectVHD reads the current size and disk type from a VHD footer.
+func detectVHD(footer []byte) (Info, error) {
+	if len(footer) < 64 {
+		return Info{}, fmt.Errorf("truncated VHD footer")
+	}
+	detail := "unknown"
+	switch binary.BigEndian.Uint32(footer[60:]) {
+	case 2:
+		detail = "fixed"
+	case 3:
+		detail = "dynamic"
+	case 4:
+		detail = "differencing"
+	}
+	return Info{
+		Format:      "vhd",
+		VirtualSize: int64(binary.BigEndian.Uint64(footer[48:])),
+		Detail:      detail,
+	}, nil
+}
+
+// detectVDI reads the disk size from a VirtualBox VDI header.
+func detectVDI(r io.ReaderAt) (Info, error) {
+	buf := make([]byte, 8)
+	if _, err := r.ReadAt(buf, 0x170); err != nil {
+		return Info{}, fmt.Errorf("truncated VDI header: %v", err)
+	}
+	return Info{Format: "vdi", VirtualSize: int64(binary.LittleEndian.Uint64(buf))}, nil
+}
+
+// detectVHDX walks the region table to the metadata region and reads
+// the virtual disk size item.
+func detectVHDX(r io.ReaderAt) (Info, error) {
+	info := Info{Format: "vhdx"}
+
+	// region table lives at 192 KiB, 16 byte header then 32 byte entries
+	regions := make([]byte, 64*1024)
+	if _, err := r.ReadAt(regions, 192*1024); err != nil && err != io.EOF {
+		return info, fmt.Errorf("failed to read VHDX region table: %v", err)
+	}
+	if string(regions[:4]) != "regi" {
+		return info, fmt.Errorf("invalid VHDX region table signature")
+	}
+
+	var metaOffset int64 = -1
+	count := binary.LittleEndian.Uint32(regions[8:])
+	for i := uint32(0); i < count && 16+int(i+1)*32 <= len(regions); i++ {
+		entry := regions[16+i*32:]
+		if bytes.Equal(entry[:16], vhdxMetadataRegion) {
+			metaOffset = int64(binary.LittleEndian.Uint64(entry[16:]))
+			break
+		}
+	}
+	if metaOffset < 0 {
+		return info, fmt.Errorf("VHDX metadata region not found")
+	}
+
+	// metadata table: 32 byte header then 32 byte entries
+	table := make([]byte, 64*1024)
+	if _, err := r.ReadAt(table, metaOffset); err != nil && err != io.EOF {
+		return info, fmt.Errorf("failed to read VHDX metadata table: %v", err)
+	}
+	if string(table[:8]) != "metadata" {
+		return info, fmt.Errorf("invalid VHDX metadata table signature")
+	}
+
+	entries := binary.LittleEndian.Uint16(table[10:])
+	for i := uint16(0); i < entries && 32+int(i+1)*32 <= len(table); i++ {
+		entry := table[32+int(i)*32:]
+		if !bytes.Equal(entry[:16], vhdxVirtualDiskSize) {
+			continue
+		}
+		itemOffset := int64(binary.LittleEndian.Uint32(entry[16:]))
+		buf := make([]byte, 8)
+		if _, err := r.ReadAt(buf, metaOffset+itemOffset); err != nil {
+			return info, fmt.Errorf("failed to read VHDX virtual disk size: %v", err)
+		}
+		info.VirtualSize = int64(binary.LittleEndian.Uint64(buf))
+		return info, nil
+	}
+
+	return info, fmt.Errorf("VHDX virtual disk size not found")
+}
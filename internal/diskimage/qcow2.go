@@ -0,0 +1,348 @@
+package diskimage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"syscall"
+)
+
+// QCOW2 layout written by QCOW2Stream: version 2, 64 KiB clusters and
+// 16-bit refcounts
+const (
+	qcow2ClusterBits  = 16
+	qcow2ClusterSize  = 1 << qcow2ClusterBits
+	qcow2L2Entries    = qcow2ClusterSize / 8 // guest clusters per L2 table
+	qcow2RefsPerBlock = qcow2ClusterSize / 2 // file clusters per refcount block
+	qcow2Copied       = uint64(1) << 63      // QCOW_OFLAG_COPIED, refcount is 1
+)
+
+// RawDisk is a raw disk image to convert
+type RawDisk interface {
+	io.ReaderAt
+	// NextData returns the next region at or after off that may hold
+	// data; start is the disk size when only holes are left
+	NextData(off int64) (start, end int64)
+}
+
+// AsRawDisk returns r as a RawDisk of size bytes. Files find their holes
+// with SEEK_DATA/SEEK_HOLE; other readers are taken as all data.
+func AsRawDisk(r io.ReaderAt, size int64) RawDisk {
+	switch disk := r.(type) {
+	case RawDisk:
+		return disk
+	case *os.File:
+		return &rawFile{File: disk, size: size}
+	}
+	return &denseDisk{ReaderAt: r, size: size}
+}
+
+type rawFile struct {
+	*os.File
+	size int64
+}
+
+func (f *rawFile) NextData(off int64) (int64, int64) {
+	return fileNextData(f.File, off, 0, f.size)
+}
+
+type denseDisk struct {
+	io.ReaderAt
+	size int64
+}
+
+func (d *denseDisk) NextData(off int64) (int64, int64) {
+	return off, d.size
+}
+
+// fileNextData finds the next data region of f at or after off, in the
+// part of the file from base that is limit bytes long. Returned offsets
+// are relative to base. Without SEEK_DATA support everything is data.
+// Only ReadAt may be used on f, as this moves its offset.
+func fileNextData(f *os.File, off, base, limit int64) (int64, int64) {
+	if off >= limit {
+		return limit, limit
+	}
+	if seekData < 0 {
+		return off, limit
+	}
+
+	start, err := f.Seek(base+off, seekData)
+	if errors.Is(err, syscall.ENXIO) {
+		return limit, limit // only a hole is left
+	}
+	if err != nil {
+		return off, limit
+	}
+	end, err := f.Seek(start, seekHole)
+	if err != nil {
+		end = base + limit
+	}
+
+	start, end = start-base, end-base
+	if start >= limit {
+		return limit, limit
+	}
+	return start, min(end, limit)
+}
+
+// QCOW2Stream converts a raw disk into a QCOW2 image as it's read, for
+// uploading. Holes and all-zero clusters are left out, so the stream is
+// about as large as the data on the disk. It is seekable, to restart a
+// failed upload.
+type QCOW2Stream struct {
+	Size     int64 // length of the QCOW2 stream
+	DataSize int64 // bytes of guest data in it
+
+	disk        RawDisk
+	virtualSize int64
+	allocated   []uint64 // bit per guest cluster holding data
+	l2Table     []int    // L2 table number of each L1 entry, -1 if none
+	l2Used      []int    // L1 entry of each L2 table
+	rankBase    []int64  // data clusters before each L1 entry
+
+	l1Clusters, rtClusters, refBlocks int64
+	l1Start, rtStart, rbStart         int64
+	l2Start, dataStart, total         int64
+
+	pos      int64
+	buf      []byte
+	bufIndex int64 // stream cluster in buf, -1 if none
+	// last data cluster produced and its guest cluster, so sequential
+	// reads find the next one without rescanning
+	lastData, lastGuest int64
+}
+
+// NewQCOW2Stream scans disk for clusters holding data, which reads all
+// of its data regions once, and lays out the QCOW2 image for them.
+func NewQCOW2Stream(disk RawDisk, virtualSize int64) (*QCOW2Stream, error) {
+	clusters := (virtualSize + qcow2ClusterSize - 1) / qcow2ClusterSize
+	s := &QCOW2Stream{
+		disk:        disk,
+		virtualSize: virtualSize,
+		allocated:   make([]uint64, (clusters+63)/64),
+		buf:         make([]byte, qcow2ClusterSize),
+		bufIndex:    -1,
+	}
+
+	// read data regions a few clusters at a time, marking non-zero ones
+	chunk := make([]byte, 16*qcow2ClusterSize)
+	for off := int64(0); off < virtualSize; {
+		start, end := disk.NextData(off)
+		if start >= virtualSize {
+			break
+		}
+		// round out to whole clusters
+		start = start / qcow2ClusterSize * qcow2ClusterSize
+		end = min((end+qcow2ClusterSize-1)/qcow2ClusterSize*qcow2ClusterSize, virtualSize)
+		if end <= start {
+			end = min(start+qcow2ClusterSize, virtualSize)
+		}
+
+		for pos := start; pos < end; {
+			n := min(int64(len(chunk)), end-pos)
+			got, err := disk.ReadAt(chunk[:n], pos)
+			if err != nil && !(err == io.EOF && int64(got) == n) {
+				return nil, fmt.Errorf("failed to read disk at %d: %v", pos, err)
+			}
+			for c := int64(0); c < n; c += qcow2ClusterSize {
+				if !isZero(chunk[c:min(c+qcow2ClusterSize, n)]) {
+					cluster := (pos + c) / qcow2ClusterSize
+					s.allocated[cluster/64] |= 1 << (cluster % 64)
+				}
+			}
+			pos += n
+		}
+		off = end
+	}
+
+	l1Size := (clusters + qcow2L2Entries - 1) / qcow2L2Entries
+	s.l2Table = make([]int, l1Size)
+	s.rankBase = make([]int64, l1Size)
+	var dataClusters int64
+	for j := int64(0); j < l1Size; j++ {
+		s.rankBase[j] = dataClusters
+		count := s.countAllocated(j*qcow2L2Entries, min((j+1)*qcow2L2Entries, clusters))
+		s.l2Table[j] = -1
+		if count > 0 {
+			s.l2Table[j] = len(s.l2Used)
+			s.l2Used = append(s.l2Used, int(j))
+		}
+		dataClusters += count
+	}
+	s.DataSize = dataClusters * qcow2ClusterSize
+
+	// the refcount blocks have to cover themselves too
+	s.l1Clusters = (l1Size*8 + qcow2ClusterSize - 1) / qcow2ClusterSize
+	for {
+		s.total = 1 + s.l1Clusters + s.rtClusters + s.refBlocks + int64(len(s.l2Used)) + dataClusters
+		refBlocks := (s.total + qcow2RefsPerBlock - 1) / qcow2RefsPerBlock
+		rtClusters := (refBlocks*8 + qcow2ClusterSize - 1) / qcow2ClusterSize
+		if refBlocks == s.refBlocks && rtClusters == s.rtClusters {
+			break
+		}
+		s.refBlocks, s.rtClusters = refBlocks, rtClusters
+	}
+
+	s.l1Start = 1
+	s.rtStart = s.l1Start + s.l1Clusters
+	s.rbStart = s.rtStart + s.rtClusters
+	s.l2Start = s.rbStart + s.refBlocks
+	s.dataStart = s.l2Start + int64(len(s.l2Used))
+	s.Size = s.total * qcow2ClusterSize
+	s.lastData, s.lastGuest = -1, -1
+	return s, nil
+}
+
+// Read implements io.Reader.
+func (s *QCOW2Stream) Read(p []byte) (int, error) {
+	if s.pos >= s.Size {
+		return 0, io.EOF
+	}
+	index := s.pos / qcow2ClusterSize
+	if index != s.bufIndex {
+		if err := s.fillCluster(index); err != nil {
+			return 0, err
+		}
+		s.bufIndex = index
+	}
+	n := copy(p, s.buf[s.pos%qcow2ClusterSize:])
+	s.pos += int64(n)
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (s *QCOW2Stream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	case io.SeekEnd:
+		offset += s.Size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative seek position")
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+// fillCluster generates cluster index of the stream into buf
+func (s *QCOW2Stream) fillCluster(index int64) error {
+	buf := s.buf
+	clear(buf)
+	be := binary.BigEndian
+
+	switch {
+	case index == 0:
+		copy(buf, "QFI\xfb")
+		be.PutUint32(buf[4:], 2)
+		be.PutUint32(buf[20:], qcow2ClusterBits)
+		be.PutUint64(buf[24:], uint64(s.virtualSize))
+		be.PutUint32(buf[36:], uint32(len(s.l2Table)))
+		be.PutUint64(buf[40:], uint64(s.l1Start*qcow2ClusterSize))
+		be.PutUint64(buf[48:], uint64(s.rtStart*qcow2ClusterSize))
+		be.PutUint32(buf[56:], uint32(s.rtClusters))
+
+	case index < s.rtStart:
+		first := (index - s.l1Start) * qcow2L2Entries
+		for e := int64(0); e < qcow2L2Entries && first+e < int64(len(s.l2Table)); e++ {
+			if table := s.l2Table[first+e]; table >= 0 {
+				be.PutUint64(buf[e*8:], uint64((s.l2Start+int64(table))*qcow2ClusterSize)|qcow2Copied)
+			}
+		}
+
+	case index < s.rbStart:
+		first := (index - s.rtStart) * qcow2L2Entries
+		for e := int64(0); e < qcow2L2Entries && first+e < s.refBlocks; e++ {
+			be.PutUint64(buf[e*8:], uint64((s.rbStart+first+e)*qcow2ClusterSize))
+		}
+
+	case index < s.l2Start:
+		first := (index - s.rbStart) * qcow2RefsPerBlock
+		for e := int64(0); e < qcow2RefsPerBlock && first+e < s.total; e++ {
+			be.PutUint16(buf[e*2:], 1)
+		}
+
+	case index < s.dataStart:
+		j := int64(s.l2Used[index-s.l2Start])
+		rank := s.rankBase[j]
+		for e := int64(0); e < qcow2L2Entries; e++ {
+			if s.isAllocated(j*qcow2L2Entries + e) {
+				be.PutUint64(buf[e*8:], uint64((s.dataStart+rank)*qcow2ClusterSize)|qcow2Copied)
+				rank++
+			}
+		}
+
+	default:
+		guest := s.guestCluster(index - s.dataStart)
+		off := guest * qcow2ClusterSize
+		n := min(int64(qcow2ClusterSize), s.virtualSize-off)
+		got, err := s.disk.ReadAt(buf[:n], off)
+		if err != nil && !(err == io.EOF && int64(got) == n) {
+			return fmt.Errorf("failed to read disk at %d: %v", off, err)
+		}
+	}
+	return nil
+}
+
+// guestCluster returns the guest cluster of the nth data cluster
+func (s *QCOW2Stream) guestCluster(n int64) int64 {
+	if n <= s.lastData {
+		s.lastData, s.lastGuest = -1, -1
+	}
+	guest := s.lastGuest
+	for ; s.lastData < n; s.lastData++ {
+		guest++
+		for !s.isAllocated(guest) {
+			guest++
+		}
+	}
+	s.lastGuest = guest
+	return guest
+}
+
+func (s *QCOW2Stream) isAllocated(cluster int64) bool {
+	if cluster/64 >= int64(len(s.allocated)) {
+		return false
+	}
+	return s.allocated[cluster/64]&(1<<(cluster%64)) != 0
+}
+
+// countAllocated counts the data clusters in [from, to)
+func (s *QCOW2Stream) countAllocated(from, to int64) int64 {
+	var count int64
+	for c := from; c < to; {
+		if c%64 == 0 && c+64 <= to {
+			count += int64(bits.OnesCount64(s.allocated[c/64]))
+			c += 64
+			continue
+		}
+		if s.isAllocated(c) {
+			count++
+		}
+		c++
+	}
+	return count
+}
+
+// isZero reports whether b is all zero bytes
+func isZero(b []byte) bool {
+	for len(b) >= 8 {
+		if binary.LittleEndian.Uint64(b) != 0 {
+			return false
+		}
+		b = b[8:]
+	}
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
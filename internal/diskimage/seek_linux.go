@@ -0,0 +1,7 @@
+package diskimage
+
+// lseek whence values for finding data and holes in sparse files
+const (
+	seekData = 3 // SEEK_DATA
+	seekHole = 4 // SEEK_HOLE
+)
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package diskimage
+
+// Without SEEK_DATA/SEEK_HOLE, files are scanned in full for zeroes
+const (
+	seekData = -1
+	seekHole = -1
+)
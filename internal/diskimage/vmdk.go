@@ -0,0 +1,566 @@
+package diskimage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// noParentCID is the parentCID of a VMDK that isn't a snapshot delta.
+const noParentCID = "ffffffff"
+
+// VMDKExtent is one line of a descriptor's extent description.
+type VMDKExtent struct {
+	Access  string // RW, RDONLY or NOACCESS
+	Sectors int64  // size of the extent in 512 byte sectors
+	Type    string // FLAT, VMFS, SPARSE, VMFSSPARSE, SESPARSE, ZERO, VMFSRDM, ...
+	Path    string // absolute path of the extent file, empty for ZERO
+	Offset  int64  // sectors to skip at the start of a flat extent file
+}
+
+// VMDKDescriptor is a parsed VMDK descriptor, either a small text .vmdk
+// file or the one embedded in a monolithic sparse extent.
+type VMDKDescriptor struct {
+	Path            string
+	CID             string
+	ParentCID       string
+	CreateType      string
+	ParentHint      string // absolute path of the parent descriptor, for deltas
+	Extents         []VMDKExtent
+	AdapterType     string // ddb.adapterType: ide, buslogic, lsilogic, lsisas1068, pvscsi, legacyESX
+	Cylinders       int
+	Heads           int
+	SectorsPerTrack int
+	Parent          *VMDKDescriptor // next descriptor down the snapshot chain
+}
+
+// ParseVMDK reads the descriptor at path, following parentFileNameHint
+// through any snapshot delta chain.
+func ParseVMDK(path string) (*VMDKDescriptor, error) {
+	seen := make(map[string]bool)
+	var top, child *VMDKDescriptor
+	for path != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if seen[abs] {
+			return nil, fmt.Errorf("snapshot chain loops back to %s", abs)
+		}
+		seen[abs] = true
+
+		desc, err := parseVMDKFile(abs)
+		if err != nil {
+			if child != nil {
+				return nil, fmt.Errorf("parent of snapshot delta %s: %v", child.Path, err)
+			}
+			return nil, err
+		}
+		if top == nil {
+			top = desc
+		} else {
+			child.Parent = desc
+		}
+		child = desc
+
+		path = ""
+		if desc.IsDelta() {
+			if desc.ParentHint == "" {
+				return nil, fmt.Errorf("%s is a snapshot delta without a parentFileNameHint", abs)
+			}
+			path = desc.ParentHint
+		}
+	}
+	return top, nil
+}
+
+// IsVMDKDescriptor reports whether path is a text VMDK descriptor.
+func IsVMDKDescriptor(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	head := make([]byte, 21)
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return string(head) == "# Disk DescriptorFile"
+}
+
+// parseVMDKFile reads one descriptor, without following its parent.
+func parseVMDKFile(path string) (*VMDKDescriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	head = head[:n]
+
+	var text []byte
+	switch {
+	case bytes.HasPrefix(head, []byte("# Disk DescriptorFile")):
+		// descriptors are a few KB at most, anything bigger isn't one
+		text, err = io.ReadAll(io.LimitReader(f, 1024*1024))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+	case bytes.HasPrefix(head, []byte("KDMV")) && len(head) >= 44:
+		// monolithic sparse and streamOptimized files embed their descriptor
+		offset := int64(binary.LittleEndian.Uint64(head[28:])) * 512
+		length := int64(binary.LittleEndian.Uint64(head[36:])) * 512
+		if offset == 0 || length == 0 {
+			return nil, fmt.Errorf("%s is a sparse extent without a descriptor; pass the descriptor .vmdk instead", path)
+		}
+		text = make([]byte, length)
+		if _, err := f.ReadAt(text, offset); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read embedded descriptor of %s: %v", path, err)
+		}
+		text = bytes.TrimRight(text, "\x00")
+	default:
+		return nil, fmt.Errorf("%s is not a VMDK descriptor", path)
+	}
+
+	desc, err := parseVMDKDescriptor(text, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	desc.Path = path
+	return desc, nil
+}
+
+// parseVMDKDescriptor parses descriptor text; relative file names are
+// resolved against dir.
+func parseVMDKDescriptor(text []byte, dir string) (*VMDKDescriptor, error) {
+	desc := &VMDKDescriptor{ParentCID: noParentCID}
+
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "RW", "RDONLY", "NOACCESS":
+			extent, err := parseVMDKExtent(line, dir)
+			if err != nil {
+				return nil, err
+			}
+			desc.Extents = append(desc.Extents, extent)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		switch key {
+		case "CID":
+			desc.CID = value
+		case "parentCID":
+			desc.ParentCID = strings.ToLower(value)
+		case "createType":
+			desc.CreateType = value
+		case "parentFileNameHint":
+			// ESXi writes absolute /vmfs/volumes/... hints, which don't
+			// exist on an NFS mount of the datastore; the parent is then
+			// looked for next to the delta
+			if !filepath.IsAbs(value) {
+				value = filepath.Join(dir, value)
+			} else if _, err := os.Stat(value); err != nil {
+				value = filepath.Join(dir, filepath.Base(value))
+			}
+			desc.ParentHint = value
+		case "ddb.adapterType":
+			desc.AdapterType = value
+		case "ddb.geometry.cylinders":
+			desc.Cylinders, _ = strconv.Atoi(value)
+		case "ddb.geometry.heads":
+			desc.Heads, _ = strconv.Atoi(value)
+		case "ddb.geometry.sectors":
+			desc.SectorsPerTrack, _ = strconv.Atoi(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(desc.Extents) == 0 {
+		return nil, fmt.Errorf("descriptor has no extents")
+	}
+	return desc, nil
+}
+
+// parseVMDKExtent parses an extent line such as
+//
+//	RW 41943040 VMFS "disk-flat.vmdk"
+//	RW 8388608 FLAT "disk-f001.vmdk" 0
+//	RW 1048576 ZERO
+func parseVMDKExtent(line, dir string) (VMDKExtent, error) {
+	var extent VMDKExtent
+
+	// the file name is quoted and may contain spaces
+	before, rest, quoted := strings.Cut(line, `"`)
+	fields := strings.Fields(before)
+	if len(fields) < 3 {
+		return extent, fmt.Errorf("invalid extent line %q", line)
+	}
+	extent.Access = fields[0]
+	extent.Type = strings.ToUpper(fields[2])
+
+	sectors, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return extent, fmt.Errorf("invalid extent size in %q", line)
+	}
+	extent.Sectors = sectors
+
+	if extent.Type == "ZERO" {
+		return extent, nil
+	}
+	if !quoted {
+		return extent, fmt.Errorf("extent line %q has no file name", line)
+	}
+
+	name, tail, ok := strings.Cut(rest, `"`)
+	if !ok {
+		return extent, fmt.Errorf("unterminated file name in %q", line)
+	}
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+	extent.Path = name
+
+	if tail = strings.TrimSpace(tail); tail != "" {
+		offset, err := strconv.ParseInt(strings.Fields(tail)[0], 10, 64)
+		if err != nil {
+			return extent, fmt.Errorf("invalid extent offset in %q", line)
+		}
+		extent.Offset = offset
+	}
+	return extent, nil
+}
+
+// IsDelta reports whether the descriptor is a snapshot delta on top of a parent.
+func (d *VMDKDescriptor) IsDelta() bool {
+	return d.ParentCID != "" && d.ParentCID != noParentCID
+}
+
+// Chain returns the descriptor file names from d down to the base disk.
+func (d *VMDKDescriptor) Chain() []string {
+	var chain []string
+	for cur := d; cur != nil; cur = cur.Parent {
+		chain = append(chain, filepath.Base(cur.Path))
+	}
+	return chain
+}
+
+// Capacity returns the virtual disk size in bytes.
+func (d *VMDKDescriptor) Capacity() int64 {
+	var sectors int64
+	for _, e := range d.Extents {
+		sectors += e.Sectors
+	}
+	return sectors * 512
+}
+
+// DiskBus maps ddb.adapterType to a Nova disk_bus, or "" if unknown.
+func (d *VMDKDescriptor) DiskBus() string {
+	switch strings.ToLower(d.AdapterType) {
+	case "ide":
+		return "ide"
+	case "buslogic", "lsilogic", "lsisas1068", "pvscsi", "legacyesx":
+		return "scsi"
+	}
+	return ""
+}
+
+// Children returns the descriptors next to d whose parentFileNameHint
+// points at it, i.e. snapshots that haven't been consolidated yet.
+func (d *VMDKDescriptor) Children() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(d.Path))
+	if err != nil {
+		return nil, err
+	}
+
+	var children []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".vmdk") {
+			continue
+		}
+		path := filepath.Join(filepath.Dir(d.Path), entry.Name())
+		if path == d.Path || !IsVMDKDescriptor(path) {
+			continue
+		}
+		child, err := parseVMDKFile(path)
+		if err != nil {
+			continue
+		}
+		if child.IsDelta() && child.ParentHint == d.Path {
+			children = append(children, entry.Name())
+		}
+	}
+	return children, nil
+}
+
+// OpenVMDK opens the data of a base disk for upload. A single sparse
+// extent is sent as-is in vmdk format; flat, VMFS and ZERO extents are
+// stitched together into one raw stream, which also covers split 2GB
+// flat disks. Snapshot deltas, split sparse disks and raw device
+// mappings are refused.
+func (d *VMDKDescriptor) OpenVMDK() (*VMDKData, error) {
+	if d.IsDelta() {
+		return nil, fmt.Errorf("%s is a snapshot delta (chain: %s); consolidate the snapshots in vSphere before migrating",
+			filepath.Base(d.Path), strings.Join(d.Chain(), " -> "))
+	}
+
+	if len(d.Extents) == 1 && d.Extents[0].Type == "SPARSE" {
+		f, err := os.Open(d.Extents[0].Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open extent: %v", err)
+		}
+		st, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to stat extent: %v", err)
+		}
+		return &VMDKData{Format: "vmdk", Size: st.Size(), r: f, files: []*os.File{f}}, nil
+	}
+
+	data := &VMDKData{Format: "raw"}
+	for _, e := range d.Extents {
+		switch e.Type {
+		case "FLAT", "VMFS":
+		case "ZERO":
+			data.segments = append(data.segments, vmdkSegment{length: e.Sectors * 512})
+			continue
+		case "SPARSE":
+			data.Close()
+			return nil, fmt.Errorf("%s is split into %d sparse extents, which can't be uploaded as one image; convert it first (e.g. vmware-vdiskmanager -r %s -t 0 new.vmdk)",
+				filepath.Base(d.Path), len(d.Extents), filepath.Base(d.Path))
+		case "VMFSSPARSE", "SESPARSE":
+			data.Close()
+			return nil, fmt.Errorf("%s has %s delta extents; consolidate the snapshots in vSphere before migrating", filepath.Base(d.Path), e.Type)
+		default:
+			data.Close()
+			return nil, fmt.Errorf("%s has a %s extent (raw device mapping?), which can't be migrated from a file", filepath.Base(d.Path), e.Type)
+		}
+
+		f, err := os.Open(e.Path)
+		if err != nil {
+			data.Close()
+			return nil, fmt.Errorf("failed to open extent: %v", err)
+		}
+		data.files = append(data.files, f)
+
+		st, err := f.Stat()
+		if err != nil {
+			data.Close()
+			return nil, fmt.Errorf("failed to stat extent: %v", err)
+		}
+		offset, length := e.Offset*512, e.Sectors*512
+		if st.Size() < offset+length {
+			data.Close()
+			return nil, fmt.Errorf("extent %s is %d bytes, descriptor expects at least %d", e.Path, st.Size(), offset+length)
+		}
+		data.segments = append(data.segments, vmdkSegment{file: f, offset: offset, length: length})
+	}
+
+	for _, s := range data.segments {
+		data.Size += s.length
+	}
+	data.r = data
+	return data, nil
+}
+
+// VMDKData is the uploadable data of a VMDK, readable and seekable
+// so failed uploads can be retried.
+type VMDKData struct {
+	Format string // Glance disk_format of the stream: raw or vmdk
+	Size   int64  // length of the stream in bytes
+
+	r        io.ReadSeeker
+	files    []*os.File
+	segments []vmdkSegment
+	pos      int64
+}
+
+// vmdkSegment is one extent's share of a raw stream; a nil file reads as zeros.
+type vmdkSegment struct {
+	file   *os.File
+	offset int64
+	length int64
+}
+
+// Read reads from the stitched extents, or the single sparse file.
+func (v *VMDKData) Read(p []byte) (int, error) {
+	if v.r != v {
+		return v.r.Read(p)
+	}
+	if v.pos >= v.Size {
+		return 0, io.EOF
+	}
+
+	start := int64(0)
+	for _, s := range v.segments {
+		if v.pos >= start+s.length {
+			start += s.length
+			continue
+		}
+		within := v.pos - start
+		if remaining := s.length - within; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		if s.file == nil {
+			clear(p)
+			v.pos += int64(len(p))
+			return len(p), nil
+		}
+		n, err := s.file.ReadAt(p, s.offset+within)
+		v.pos += int64(n)
+		if err == io.EOF && n == len(p) {
+			err = nil
+		}
+		return n, err
+	}
+	return 0, io.EOF
+}
+
+// ReadAt reads the stitched extents of a raw stream at off.
+func (v *VMDKData) ReadAt(p []byte, off int64) (int, error) {
+	if v.r != v {
+		return 0, fmt.Errorf("ReadAt needs a raw stream, this is %s", v.Format)
+	}
+
+	read := 0
+	start := int64(0)
+	for _, s := range v.segments {
+		if len(p) == 0 {
+			break
+		}
+		if off >= start+s.length {
+			start += s.length
+			continue
+		}
+		within := off - start
+		chunk := p
+		if remaining := s.length - within; int64(len(chunk)) > remaining {
+			chunk = chunk[:remaining]
+		}
+		if s.file == nil {
+			clear(chunk)
+		} else if n, err := s.file.ReadAt(chunk, s.offset+within); err != nil && !(err == io.EOF && n == len(chunk)) {
+			return read + n, err
+		}
+		read += len(chunk)
+		off += int64(len(chunk))
+		p = p[len(chunk):]
+		start += s.length
+	}
+	if len(p) > 0 {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+// NextData returns the next region of a raw stream at or after off that
+// may hold data. ZERO extents and holes in sparse extent files are
+// skipped; start is Size when only those are left.
+func (v *VMDKData) NextData(off int64) (int64, int64) {
+	start := int64(0)
+	for _, s := range v.segments {
+		if off >= start+s.length {
+			start += s.length
+			continue
+		}
+		if s.file != nil {
+			within := max(off-start, 0)
+			dataStart, dataEnd := fileNextData(s.file, within, s.offset, s.length)
+			if dataStart < s.length {
+				return start + dataStart, start + dataEnd
+			}
+		}
+		start += s.length
+	}
+	return v.Size, v.Size
+}
+
+// Seek implements io.Seeker.
+func (v *VMDKData) Seek(offset int64, whence int) (int64, error) {
+	if v.r != v {
+		return v.r.Seek(offset, whence)
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += v.pos
+	case io.SeekEnd:
+		offset += v.Size
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative seek position")
+	}
+	v.pos = offset
+	return offset, nil
+}
+
+// Close closes all extent files.
+func (v *VMDKData) Close() error {
+	for _, f := range v.files {
+		f.Close()
+	}
+	return nil
+}
+
+// Locks returns the lock files held on the disk while its VM runs:
+// "<file>.lck" directories next to the descriptor or an extent, and the
+// ".lck-*" files ESXi keeps in the VM directory on NFS datastores.
+func (d *VMDKDescriptor) Locks() []string {
+	var locks []string
+	seen := make(map[string]bool)
+	check := func(path string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		if _, err := os.Stat(path); err == nil {
+			locks = append(locks, path)
+		}
+	}
+
+	check(d.Path + ".lck")
+	for _, e := range d.Extents {
+		check(e.Path + ".lck")
+	}
+
+	dir := filepath.Dir(d.Path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return locks
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".lck-") {
+			check(filepath.Join(dir, entry.Name()))
+		}
+	}
+	return locks
+}
@@ -69,6 +69,12 @@ func SendRequest(url string, jsonData []byte) (*http.Response, error) {
 
 // SendRequestWithToken can handle both GET and POST requests with a timeout and a custom User-Agent.
 func SendRequestWithToken(method, url, token string, body io.Reader) (*http.Response, error) {
+	return SendRequestWithHeaders(method, url, token, body, nil)
+}
+
+// SendRequestWithHeaders is SendRequestWithToken with extra headers that override
+// the defaults, e.g. a newer microversion for a single call.
+func SendRequestWithHeaders(method, url, token string, body io.Reader, headers map[string]string) (*http.Response, error) {
 	// Read and reassign the body for logging if it's not nil
 	var bodyBytes []byte
 	if body != nil && viper.GetBool("debug") {
@@ -82,7 +88,7 @@ func SendRequestWithToken(method, url, token string, body io.Reader) (*http.Resp
 	}
 
 	// Add headers
-	if method == "POST" && body != nil {
+	if (method == "POST" || method == "PUT" || method == "PATCH") && body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
 	req.Header.Set("Accept", "application/json")
@@ -93,6 +99,9 @@ func SendRequestWithToken(method, url, token string, body io.Reader) (*http.Resp
 
 	// We need this header set for BlockDeviceMappingV2.VolumeType
 	req.Header.Set("X-OpenStack-Nova-API-Version", "2.72")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
 	if viper.GetBool("debug") {
 		printDebugDivider("request")
@@ -26,18 +26,54 @@ func (cr *countingReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
-func UploadBigFile(url, token string, data io.Reader) (*http.Response, error) {
-	var size int64
-	if f, ok := data.(*os.File); ok {
+// UploadOptions tunes a large upload.
+type UploadOptions struct {
+	Size    int64     // content length; 0 derives it from a regular *os.File, else unknown
+	BwLimit int64     // bytes per second, 0 for unlimited
+	Output  io.Writer // where progress is drawn, nil for stdout
+}
+
+// throttledReader caps the average read rate at limit bytes per second.
+type throttledReader struct {
+	r     io.Reader
+	limit int64
+	start time.Time
+	read  int64
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	// keep reads small so the rate stays smooth at low limits
+	if max := tr.limit / 10; max > 0 && int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := tr.r.Read(p)
+	tr.read += int64(n)
+
+	// sleep until the bytes read so far fit within the limit
+	expected := time.Duration(float64(tr.read) / float64(tr.limit) * float64(time.Second))
+	if wait := expected - time.Since(tr.start); wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+func UploadBigFile(url, token string, data io.Reader, opts UploadOptions) (*http.Response, error) {
+	size := opts.Size
+	if f, ok := data.(*os.File); ok && size == 0 {
 		info, err := f.Stat()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get file size: %v", err)
 		}
-		size = info.Size()
+		if info.Mode().IsRegular() {
+			size = info.Size()
+			if size == 0 {
+				return nil, fmt.Errorf("refusing to upload empty file (size=0)")
+			}
+		}
 	}
 
-	if size == 0 {
-		return nil, fmt.Errorf("refusing to upload empty file (size=0)")
+	if opts.BwLimit > 0 {
+		data = &throttledReader{r: data, limit: opts.BwLimit, start: time.Now()}
 	}
 
 	uploadedBytes := atomic.Int64{}
@@ -47,13 +83,17 @@ func UploadBigFile(url, token string, data io.Reader) (*http.Response, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
+	// pipes and other streams of unknown length go out chunked
+	req.ContentLength = size
+	if size <= 0 {
+		req.ContentLength = -1
+	}
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.Header.Set("X-Auth-Token", token)
-	req.Header.Set("Content-Length", fmt.Sprintf("%d", size))
 	req.Header.Del("Expect") // Prevent 100-Continue delays
 
 	if viper.GetBool("debug") {
-		fmt.Printf("Starting upload (size: %d bytes)\n", size)
+		fmt.Printf("Starting upload (size: %d bytes, bwlimit: %d B/s)\n", size, opts.BwLimit)
 	}
 
 	transport := &http.Transport{
@@ -66,6 +106,8 @@ func UploadBigFile(url, token string, data io.Reader) (*http.Response, error) {
 		}).DialContext,
 		WriteBufferSize: 64 * 1024,
 		ReadBufferSize:  64 * 1024,
+		// a stalled server should fail the attempt so it can be retried
+		ResponseHeaderTimeout: 30 * time.Minute,
 	}
 
 	client := &http.Client{
@@ -76,13 +118,17 @@ func UploadBigFile(url, token string, data io.Reader) (*http.Response, error) {
 	// Progress tracking
 	startTime := time.Now()
 	stopProgress := make(chan struct{})
-	go trackProgress(&uploadedBytes, size, startTime, stopProgress)
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	go trackProgress(out, &uploadedBytes, size, startTime, "Uploaded", stopProgress)
 
 	resp, err := client.Do(req)
 	close(stopProgress) // Stop progress goroutine
 
 	if err != nil {
-		return nil, fmt.Errorf("upload failed: %v", err)
+		return nil, fmt.Errorf("upload failed after %d of %d bytes: %v", uploadedBytes.Load(), size, err)
 	}
 	defer resp.Body.Close()
 
@@ -94,14 +140,87 @@ func UploadBigFile(url, token string, data io.Reader) (*http.Response, error) {
 	return resp, nil
 }
 
-func trackProgress(uploadedBytes *atomic.Int64, size int64, startTime time.Time, stopChan chan struct{}) {
-	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+// DownloadBigFile streams a large GET response into w with progress output.
+// If offset > 0 the download resumes with an HTTP Range request; servers that
+// ignore the range get the first offset bytes discarded instead.
+func DownloadBigFile(url, token string, w io.Writer, offset, size int64) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("X-Auth-Token", token)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	if viper.GetBool("debug") {
+		fmt.Printf("Starting download (size: %d bytes, offset: %d)\n", size, offset)
+	}
+
+	transport := &http.Transport{
+		DisableCompression: true,
+		ForceAttemptHTTP2:  true,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ReadBufferSize: 64 * 1024,
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   0, // No overall timeout for large downloads
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		// resuming as requested
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+				return fmt.Errorf("failed to skip %d already downloaded bytes: %v", offset, err)
+			}
+		}
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("download failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	downloadedBytes := atomic.Int64{}
+	downloadedBytes.Store(offset)
+	cr := &countingReader{r: resp.Body, uploaded: &downloadedBytes}
+
+	startTime := time.Now()
+	stopProgress := make(chan struct{})
+	go trackProgress(os.Stdout, &downloadedBytes, size, startTime, "Downloaded", stopProgress)
+
+	_, err = io.Copy(w, cr)
+	close(stopProgress)
+	fmt.Println()
+
+	if err != nil {
+		return fmt.Errorf("download interrupted at %d bytes: %v", downloadedBytes.Load(), err)
+	}
+	return nil
+}
+
+func trackProgress(out io.Writer, uploadedBytes *atomic.Int64, size int64, startTime time.Time, label string, stopChan chan struct{}) {
+	tw := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
 	defer tw.Flush()
 
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	var lastBytes int64
+	// a resumed download starts counting from its offset
+	lastBytes := uploadedBytes.Load()
 	lastTime := startTime
 	var emaSpeed float64 // Exponentially smoothed speed
 	alpha := 0.5         // Smoothing factor (adjust as needed)
@@ -134,9 +253,22 @@ func trackProgress(uploadedBytes *atomic.Int64, size int64, startTime time.Time,
 				eta = formatDuration(time.Duration(secsLeft) * time.Second)
 			}
 
+			// Streams of unknown length only get a running total
+			if size <= 0 {
+				fmt.Fprintf(tw, "\r\033[KElapsed:\t%s\t%s:\t%d MB\tSpeed:\t%s/s",
+					formatDuration(totalElapsed),
+					label,
+					current/1024/1024,
+					speedToString(emaSpeed),
+				)
+				tw.Flush()
+				continue
+			}
+
 			// Print progress
-			fmt.Fprintf(tw, "\r\033[KElapsed:\t%s\tUploaded:\t%.1f%%\tSpeed:\t%s/s\tETA:\t%s\t(%d/%d MB)",
+			fmt.Fprintf(tw, "\r\033[KElapsed:\t%s\t%s:\t%.1f%%\tSpeed:\t%s/s\tETA:\t%s\t(%d/%d MB)",
 				formatDuration(totalElapsed),
+				label,
 				float64(current)*100/float64(size),
 				speedToString(emaSpeed),
 				eta,
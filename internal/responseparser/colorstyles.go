@@ -55,6 +55,32 @@ func colorStyleVolAvailability(availability string) string {
 	}
 }
 
+// colorStyleMemberStatus returns a color-coded image member status
+// Possible values: "pending", "accepted", "rejected".
+func colorStyleMemberStatus(status string) string {
+	switch status {
+	case "accepted":
+		return color.Style{color.FgGreen, color.OpBold}.Render(status)
+	case "rejected":
+		return color.Style{color.FgRed, color.OpBold}.Render(status)
+	default:
+		return color.Style{color.FgYellow, color.OpBold}.Render(status)
+	}
+}
+
+// colorStyleMigrationStatus returns a color-coded migration status
+// Possible values: "in-progress", "failed", "completed".
+func colorStyleMigrationStatus(status string) string {
+	switch status {
+	case "completed":
+		return color.Style{color.FgGreen, color.OpBold}.Render(status)
+	case "failed":
+		return color.Style{color.FgRed, color.OpBold}.Render(status)
+	default:
+		return color.Style{color.FgYellow, color.OpBold}.Render(status)
+	}
+}
+
 // applyTableStyle configures tablewriter styles
 func applyTableStyle(table *tablewriter.Table) {
 	table.SetAutoFormatHeaders(false)
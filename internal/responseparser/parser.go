@@ -3,6 +3,7 @@ package responseparser
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/gookit/color"
 	"github.com/olekukonko/tablewriter"
@@ -64,6 +65,102 @@ func PrintProjectsTable(projects []Project) {
 	table.Render()
 }
 
+// -------------------------------------------------------------------
+// USERS AND ROLES
+// -------------------------------------------------------------------
+
+// User is a Keystone user; Roles are only set when listing a project's
+// users, and add a ROLES column
+type User struct {
+	ID              string
+	Name            string
+	DomainID        string
+	Enabled         bool
+	PasswordExpires string
+	Roles           []string
+}
+
+func PrintUsersTable(users []User) {
+	withRoles := false
+	for _, u := range users {
+		withRoles = withRoles || len(u.Roles) > 0
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	header := []string{"NAME", "ID", "DOMAIN_ID", "ENABLED", "PASSWORD EXPIRES"}
+	if withRoles {
+		header = append(header, "ROLES")
+	}
+	table.SetHeader(header)
+
+	applyTableStyle(table)
+
+	for _, u := range users {
+		expires := u.PasswordExpires
+		if expires == "" {
+			expires = "never"
+		}
+		row := []string{
+			color.Style{color.FgGreen}.Render(u.Name),
+			u.ID,
+			u.DomainID,
+			colorStyleBool(u.Enabled),
+			expires,
+		}
+		if withRoles {
+			row = append(row, strings.Join(u.Roles, ", "))
+		}
+		table.Append(row)
+	}
+	table.Render()
+}
+
+type Role struct {
+	ID       string
+	Name     string
+	DomainID string
+}
+
+func PrintRolesTable(roles []Role) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "ID", "DOMAIN_ID"})
+
+	applyTableStyle(table)
+
+	for _, r := range roles {
+		table.Append([]string{
+			color.Style{color.FgGreen}.Render(r.Name),
+			r.ID,
+			r.DomainID,
+		})
+	}
+	table.Render()
+}
+
+// RoleAssignment is a role of a user or group on a project or domain;
+// Actor and Scope are prefixed with their kind, e.g. "user:alice"
+type RoleAssignment struct {
+	Role  string
+	Actor string
+	Scope string
+}
+
+func PrintRoleAssignmentsTable(assignments []RoleAssignment) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ROLE", "USER/GROUP", "SCOPE"})
+
+	applyTableStyle(table)
+
+	for _, a := range assignments {
+		table.Append([]string{
+			color.Style{color.FgGreen}.Render(a.Role),
+			a.Actor,
+			a.Scope,
+		})
+	}
+	table.Render()
+}
+
 // -------------------------------------------------------------------
 // FLAVORS
 // -------------------------------------------------------------------
@@ -90,6 +187,71 @@ func PrintFlavorsTable(flavors []Flavor) {
 	table.Render()
 }
 
+// FlavorMatch is a flavor fitting a --cpus/--ram request; the picked
+// one is highlighted
+type FlavorMatch struct {
+	Name   string
+	ID     string
+	VCPUs  int
+	RAM    int
+	Disk   int
+	Picked bool
+}
+
+func PrintFlavorMatchTable(flavors []FlavorMatch) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "ID", "VCPUS", "RAM (MB)", "DISK (GB)", "PICKED"})
+
+	applyTableStyle(table)
+
+	for _, f := range flavors {
+		picked := ""
+		if f.Picked {
+			picked = color.Style{color.FgGreen}.Render("*")
+		}
+		table.Append([]string{
+			color.Style{color.FgGreen}.Render(f.Name),
+			f.ID,
+			fmt.Sprintf("%d", f.VCPUs),
+			fmt.Sprintf("%d", f.RAM),
+			fmt.Sprintf("%d", f.Disk),
+			picked,
+		})
+	}
+	table.Render()
+}
+
+// -------------------------------------------------------------------
+// QUOTAS
+// -------------------------------------------------------------------
+
+// Quota is one resource of a project's quotas; -1 limits are unlimited
+type Quota struct {
+	Resource string
+	Used     int
+	Limit    int
+	Free     int
+}
+
+func PrintQuotaTable(quotas []Quota) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"RESOURCE", "USED", "LIMIT", "FREE"})
+
+	applyTableStyle(table)
+
+	for _, q := range quotas {
+		limit, free := fmt.Sprintf("%d", q.Limit), fmt.Sprintf("%d", q.Free)
+		if q.Limit < 0 {
+			limit, free = "unlimited", "unlimited"
+		}
+		if q.Limit >= 0 && q.Free == 0 {
+			free = color.Style{color.FgRed}.Render(free)
+		}
+		table.Append([]string{q.Resource, fmt.Sprintf("%d", q.Used), limit, free})
+	}
+	table.Render()
+}
+
 // -------------------------------------------------------------------
 // IMAGES
 // -------------------------------------------------------------------
@@ -125,6 +287,30 @@ func PrintImagesTable(images []Image) {
 	table.Render()
 }
 
+type ImageMember struct {
+	MemberID  string
+	Status    string
+	CreatedAt string
+	UpdatedAt string
+}
+
+func PrintImageMembersTable(members []ImageMember) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"MEMBER (PROJECT)", "STATUS", "CREATED", "UPDATED"})
+
+	applyTableStyle(table)
+
+	for _, m := range members {
+		table.Append([]string{
+			color.Style{color.FgGreen}.Render(m.MemberID),
+			colorStyleMemberStatus(m.Status),
+			m.CreatedAt,
+			stringOrNA(m.UpdatedAt),
+		})
+	}
+	table.Render()
+}
+
 // -------------------------------------------------------------------
 // VOLUMES
 // -------------------------------------------------------------------
@@ -154,6 +340,35 @@ func PrintVolumesTable(volumes []Volume) {
 	table.Render()
 }
 
+// -------------------------------------------------------------------
+// VOLUME TYPES
+// -------------------------------------------------------------------
+
+type VolumeType struct {
+	ID          string
+	Name        string
+	Description string
+	IsPublic    bool
+}
+
+// PrintVolumeTypesTable prints a table of volume types.
+func PrintVolumeTypesTable(types []VolumeType) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "ID", "PUBLIC", "DESCRIPTION"})
+
+	applyTableStyle(table)
+
+	for _, t := range types {
+		table.Append([]string{
+			color.Style{color.FgGreen}.Render(t.Name),
+			t.ID,
+			colorStyleBool(t.IsPublic),
+			t.Description,
+		})
+	}
+	table.Render()
+}
+
 // -------------------------------------------------------------------
 // NETWORKS
 // -------------------------------------------------------------------
@@ -593,3 +808,71 @@ func PrintCatalogTable(entries []CatalogEntry) {
 	}
 	table.Render()
 }
+
+// -------------------------------------------------------------------
+// MIGRATIONS
+// -------------------------------------------------------------------
+
+type MigrationStatus struct {
+	Name          string
+	Status        string
+	Stage         string
+	SourceStopped bool
+	VMID          string
+	Updated       string
+	Error         string
+}
+
+func PrintMigrationStatusTable(migrations []MigrationStatus) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "STATUS", "LAST STAGE", "SOURCE OFF", "VM ID", "UPDATED", "ERROR"})
+
+	applyTableStyle(table)
+
+	for _, m := range migrations {
+		table.Append([]string{
+			color.Style{color.FgGreen}.Render(m.Name),
+			colorStyleMigrationStatus(m.Status),
+			m.Stage,
+			colorStyleBool(m.SourceStopped),
+			stringOrNA(m.VMID),
+			m.Updated,
+			stringOrNA(m.Error),
+		})
+	}
+	table.Render()
+}
+
+// -------------------------------------------------------------------
+// VMDK FILES
+// -------------------------------------------------------------------
+
+type VMDKFile struct {
+	Path      string
+	Datastore string
+	Size      string
+	Modified  string
+	Locked    bool
+}
+
+func PrintVMDKFilesTable(files []VMDKFile) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"PATH", "DATASTORE", "SIZE", "MODIFIED", "LOCKED"})
+
+	applyTableStyle(table)
+
+	for _, f := range files {
+		locked := "no"
+		if f.Locked {
+			locked = color.Style{color.FgRed, color.OpBold}.Render("LOCKED")
+		}
+		table.Append([]string{
+			color.Style{color.FgGreen}.Render(f.Path),
+			f.Datastore,
+			f.Size,
+			f.Modified,
+			locked,
+		})
+	}
+	table.Render()
+}
@@ -0,0 +1,152 @@
+package vmconfig
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/facette/natsort"
+)
+
+// libvirtDomain is the part of a libvirt domain XML a migration needs
+type libvirtDomain struct {
+	Name   string `xml:"name"`
+	VCPU   string `xml:"vcpu"`
+	Memory struct {
+		Value string `xml:",chardata"`
+		Unit  string `xml:"unit,attr"`
+	} `xml:"memory"`
+	Disks []struct {
+		Type   string `xml:"type,attr"`
+		Device string `xml:"device,attr"`
+		Source struct {
+			File string `xml:"file,attr"`
+			Dev  string `xml:"dev,attr"`
+		} `xml:"source"`
+		Target struct {
+			Dev string `xml:"dev,attr"`
+			Bus string `xml:"bus,attr"`
+		} `xml:"target"`
+		Boot *struct {
+			Order int `xml:"order,attr"`
+		} `xml:"boot"`
+	} `xml:"devices>disk"`
+	Interfaces []struct {
+		MAC struct {
+			Address string `xml:"address,attr"`
+		} `xml:"mac"`
+		Source struct {
+			Bridge  string `xml:"bridge,attr"`
+			Network string `xml:"network,attr"`
+			Dev     string `xml:"dev,attr"`
+		} `xml:"source"`
+		Target struct {
+			Dev string `xml:"dev,attr"`
+		} `xml:"target"`
+		Model struct {
+			Type string `xml:"type,attr"`
+		} `xml:"model"`
+	} `xml:"devices>interface"`
+}
+
+// libvirtUnits are the byte multipliers of libvirt memory units
+var libvirtUnits = map[string]int64{
+	"b": 1, "bytes": 1,
+	"kb": 1000, "k": 1024, "kib": 1024,
+	"mb": 1000 * 1000, "m": 1024 * 1024, "mib": 1024 * 1024,
+	"gb": 1000 * 1000 * 1000, "g": 1024 * 1024 * 1024, "gib": 1024 * 1024 * 1024,
+	"tb": 1000 * 1000 * 1000 * 1000, "t": 1024 * 1024 * 1024 * 1024, "tib": 1024 * 1024 * 1024 * 1024,
+}
+
+// ParseLibvirtXML reads a libvirt domain XML, as written by 'virsh
+// dumpxml'. Disks are file or block devices (qcow2 or raw); CD-ROMs and
+// floppies are skipped. A NIC's network is its bridge, or the libvirt
+// network or host device it's attached to.
+func ParseLibvirtXML(path string) (VM, error) {
+	var vm VM
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return vm, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	var dom libvirtDomain
+	if err := xml.Unmarshal(data, &dom); err != nil {
+		return vm, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	vm.Name = dom.Name
+	vm.VCPUs = 1
+	if v, err := strconv.Atoi(strings.TrimSpace(dom.VCPU)); err == nil {
+		vm.VCPUs = v
+	}
+
+	// memory defaults to KiB
+	unit := strings.ToLower(dom.Memory.Unit)
+	if unit == "" {
+		unit = "kib"
+	}
+	multiplier, ok := libvirtUnits[unit]
+	if !ok {
+		return vm, fmt.Errorf("unsupported memory unit %q in %s", dom.Memory.Unit, path)
+	}
+	if v, err := strconv.ParseInt(strings.TrimSpace(dom.Memory.Value), 10, 64); err == nil {
+		vm.MemoryMB = int(v * multiplier / (1024 * 1024))
+	}
+
+	// disks with a <boot order> come first, in that order
+	bootOrder := make(map[string]int)
+	for _, d := range dom.Disks {
+		if d.Device != "" && d.Device != "disk" {
+			continue
+		}
+		source := d.Source.File
+		if d.Type == "block" {
+			source = d.Source.Dev
+		}
+		if source == "" {
+			return vm, fmt.Errorf("disk %s of %s has no file or block device source", d.Target.Dev, path)
+		}
+
+		vm.Disks = append(vm.Disks, Disk{Device: d.Target.Dev, Path: source, Bus: d.Target.Bus})
+		if d.Boot != nil {
+			bootOrder[d.Target.Dev] = d.Boot.Order
+		}
+	}
+	sort.SliceStable(vm.Disks, func(i, j int) bool {
+		a, aBoot := bootOrder[vm.Disks[i].Device]
+		b, bBoot := bootOrder[vm.Disks[j].Device]
+		if aBoot != bBoot {
+			return aBoot
+		}
+		if aBoot && a != b {
+			return a < b
+		}
+		return natsort.Compare(vm.Disks[i].Device, vm.Disks[j].Device)
+	})
+
+	for i, iface := range dom.Interfaces {
+		nic := NIC{
+			Device:  iface.Target.Dev,
+			MAC:     strings.ToLower(iface.MAC.Address),
+			Network: iface.Source.Bridge,
+			Model:   iface.Model.Type,
+		}
+		if nic.Device == "" {
+			nic.Device = fmt.Sprintf("net%d", i)
+		}
+		for _, network := range []string{iface.Source.Network, iface.Source.Dev} {
+			if nic.Network == "" {
+				nic.Network = network
+			}
+		}
+		vm.NICs = append(vm.NICs, nic)
+	}
+
+	if len(vm.Disks) == 0 {
+		return vm, fmt.Errorf("%s has no disks", path)
+	}
+	return vm, nil
+}
@@ -0,0 +1,94 @@
+package vmconfig
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// OVA is an opened OVA archive. Its files are located once and then read
+// in place, so disks stream out of the tar without being unpacked.
+type OVA struct {
+	Path  string
+	f     *os.File
+	files map[string]ovaFile
+	ovf   string
+}
+
+// ovaFile is where a file's data sits in the archive
+type ovaFile struct {
+	offset int64
+	size   int64
+}
+
+// OpenOVA indexes the files of an OVA. Only headers are read; the data
+// of each file is skipped by seeking.
+func OpenOVA(path string) (*OVA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	ova := &OVA{Path: path, f: f, files: make(map[string]ovaFile)}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// the tar reader doesn't buffer, so the file is positioned at the data
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		ova.files[hdr.Name] = ovaFile{offset: offset, size: hdr.Size}
+		if ova.ovf == "" && strings.HasSuffix(strings.ToLower(hdr.Name), ".ovf") {
+			ova.ovf = hdr.Name
+		}
+	}
+
+	if ova.ovf == "" {
+		f.Close()
+		return nil, fmt.Errorf("%s has no .ovf descriptor", path)
+	}
+	return ova, nil
+}
+
+// VM parses the OVF descriptor of the archive
+func (o *OVA) VM() (VM, error) {
+	r, _, err := o.Open(o.ovf)
+	if err != nil {
+		return VM{}, err
+	}
+	return ParseOVF(r)
+}
+
+// Open returns a reader over a file in the archive and its size. Names
+// are relative to the OVF, as in its References.
+func (o *OVA) Open(name string) (*io.SectionReader, int64, error) {
+	file, ok := o.files[name]
+	if !ok {
+		file, ok = o.files[path.Join(path.Dir(o.ovf), name)]
+	}
+	if !ok {
+		return nil, 0, fmt.Errorf("%s is not in %s", name, o.Path)
+	}
+	return io.NewSectionReader(o.f, file.offset, file.size), file.size, nil
+}
+
+// Close closes the archive and every reader from Open
+func (o *OVA) Close() error {
+	return o.f.Close()
+}
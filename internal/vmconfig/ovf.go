@@ -0,0 +1,215 @@
+package vmconfig
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/facette/natsort"
+)
+
+// CIM resource types of OVF hardware items
+const (
+	ovfResourceCPU      = 3
+	ovfResourceMemory   = 4
+	ovfResourceIDE      = 5
+	ovfResourceSCSI     = 6
+	ovfResourceEthernet = 10
+	ovfResourceStorage  = 20 // other storage controller, e.g. AHCI (SATA)
+	ovfResourceDisk     = 17
+)
+
+// ovfEnvelope is the part of an OVF descriptor a migration needs. Tags
+// are matched by local name, so the ovf/rasd namespaces don't matter.
+type ovfEnvelope struct {
+	Files []struct {
+		ID          string `xml:"id,attr"`
+		Href        string `xml:"href,attr"`
+		Compression string `xml:"compression,attr"`
+		ChunkSize   int64  `xml:"chunkSize,attr"`
+	} `xml:"References>File"`
+	Disks []struct {
+		DiskID  string `xml:"diskId,attr"`
+		FileRef string `xml:"fileRef,attr"`
+	} `xml:"DiskSection>Disk"`
+	Systems     []ovfVirtualSystem `xml:"VirtualSystem"`
+	Collections []struct{}         `xml:"VirtualSystemCollection"`
+}
+
+type ovfVirtualSystem struct {
+	ID       string `xml:"id,attr"`
+	Name     string `xml:"Name"`
+	Hardware struct {
+		// OVF 2.0 splits storage and NICs out of Item
+		Items        []ovfItem `xml:"Item"`
+		StorageItems []ovfItem `xml:"StorageItem"`
+		NICItems     []ovfItem `xml:"EthernetPortItem"`
+	} `xml:"VirtualHardwareSection"`
+}
+
+type ovfItem struct {
+	InstanceID      string `xml:"InstanceID"`
+	ResourceType    int    `xml:"ResourceType"`
+	ResourceSubType string `xml:"ResourceSubType"`
+	VirtualQuantity int64  `xml:"VirtualQuantity"`
+	AllocationUnits string `xml:"AllocationUnits"`
+	Address         string `xml:"Address"`
+	AddressOnParent string `xml:"AddressOnParent"`
+	Parent          string `xml:"Parent"`
+	HostResource    string `xml:"HostResource"`
+	Connection      string `xml:"Connection"`
+}
+
+// ovfDiskFile() reports whether the file with ID fileID holds a disk
+func ovfDiskFile(env ovfEnvelope, fileID string) bool {
+	for _, d := range env.Disks {
+		if d.FileRef == fileID {
+			return true
+		}
+	}
+	return false
+}
+
+// ovfByteUnits matches programmatic units such as "byte * 2^20"
+var ovfByteUnits = regexp.MustCompile(`^byte\s*\*\s*2\^(\d+)$`)
+
+// ParseOVF reads an OVF descriptor with a single virtual system. Disk
+// paths are the file names from its References section, i.e. relative
+// to the OVF or names inside the OVA.
+func ParseOVF(r io.Reader) (VM, error) {
+	var vm VM
+	var env ovfEnvelope
+	if err := xml.NewDecoder(r).Decode(&env); err != nil {
+		return vm, fmt.Errorf("failed to parse OVF: %v", err)
+	}
+	if len(env.Collections) > 0 || len(env.Systems) != 1 {
+		return vm, fmt.Errorf("OVF describes more than one VM; only single-VM appliances are supported")
+	}
+	sys := env.Systems[0]
+
+	vm.Name = sys.Name
+	if vm.Name == "" {
+		vm.Name = sys.ID
+	}
+	vm.VCPUs = 1
+
+	hrefs := make(map[string]string)
+	for _, f := range env.Files {
+		hrefs[f.ID] = f.Href
+	}
+	diskFiles := make(map[string]string)
+	for _, d := range env.Disks {
+		diskFiles[d.DiskID] = hrefs[d.FileRef]
+	}
+
+	// Compressed or chunked disk files would need gunzipping and joining
+	// before the format can be detected; refuse them rather than upload
+	// garbage
+	for _, f := range env.Files {
+		if !ovfDiskFile(env, f.ID) {
+			continue
+		}
+		if f.Compression != "" && f.Compression != "identity" {
+			return vm, fmt.Errorf("disk file %s is %s-compressed; export the appliance again without compression", f.Href, f.Compression)
+		}
+		if f.ChunkSize > 0 {
+			return vm, fmt.Errorf("disk file %s is split into chunks of %d bytes; join the chunks into one file and update the OVF first", f.Href, f.ChunkSize)
+		}
+	}
+
+	items := append(append(sys.Hardware.Items, sys.Hardware.StorageItems...), sys.Hardware.NICItems...)
+	controllers := make(map[string]ovfItem)
+	for _, item := range items {
+		switch item.ResourceType {
+		case ovfResourceIDE, ovfResourceSCSI, ovfResourceStorage:
+			controllers[item.InstanceID] = item
+		}
+	}
+
+	for _, item := range items {
+		switch item.ResourceType {
+		case ovfResourceCPU:
+			vm.VCPUs = int(item.VirtualQuantity)
+		case ovfResourceMemory:
+			units, err := ovfUnitBytes(item.AllocationUnits)
+			if err != nil {
+				return vm, err
+			}
+			vm.MemoryMB = int(item.VirtualQuantity * units / (1024 * 1024))
+		case ovfResourceDisk:
+			// HostResource is ovf:/disk/<diskId> (or ovf:/file/<fileId>)
+			ref := item.HostResource
+			path := diskFiles[strings.TrimPrefix(ref, "ovf:/disk/")]
+			if path == "" {
+				path = hrefs[strings.TrimPrefix(ref, "ovf:/file/")]
+			}
+			if path == "" {
+				return vm, fmt.Errorf("OVF disk %s has no file", ref)
+			}
+			ctrl := controllers[item.Parent]
+			bus := ovfDiskBus(ctrl)
+			vm.Disks = append(vm.Disks, Disk{
+				Device: fmt.Sprintf("%s%s:%s", bus, ctrl.Address, item.AddressOnParent),
+				Path:   path,
+				Bus:    bus,
+			})
+		case ovfResourceEthernet:
+			vm.NICs = append(vm.NICs, NIC{
+				Device:  fmt.Sprintf("ethernet%d", len(vm.NICs)),
+				MAC:     strings.ToLower(item.Address),
+				Network: item.Connection,
+				Model:   strings.ToLower(item.ResourceSubType),
+			})
+		}
+	}
+
+	busOrder := map[string]int{"scsi": 0, "sata": 1, "ide": 2}
+	sort.SliceStable(vm.Disks, func(i, j int) bool {
+		a, b := vm.Disks[i], vm.Disks[j]
+		if a.Bus != b.Bus {
+			return busOrder[a.Bus] < busOrder[b.Bus]
+		}
+		return natsort.Compare(a.Device, b.Device)
+	})
+
+	if len(vm.Disks) == 0 {
+		return vm, fmt.Errorf("OVF has no disks")
+	}
+	return vm, nil
+}
+
+// ovfDiskBus maps the controller of a disk to a Nova disk_bus
+func ovfDiskBus(ctrl ovfItem) string {
+	switch ctrl.ResourceType {
+	case ovfResourceIDE:
+		return "ide"
+	case ovfResourceStorage:
+		return "sata"
+	}
+	return "scsi"
+}
+
+// ovfUnitBytes returns the bytes per unit of an AllocationUnits value;
+// an empty value means bytes
+func ovfUnitBytes(units string) (int64, error) {
+	units = strings.TrimSpace(units)
+	if m := ovfByteUnits.FindStringSubmatch(units); m != nil {
+		shift, _ := strconv.Atoi(m[1])
+		return int64(1) << shift, nil
+	}
+	switch strings.ToLower(units) {
+	case "", "byte", "bytes":
+		return 1, nil
+	case "kilobytes", "kb":
+		return 1024, nil
+	case "megabytes", "mb":
+		return 1024 * 1024, nil
+	case "gigabytes", "gb":
+		return 1024 * 1024 * 1024, nil
+	}
+	return 0, fmt.Errorf("unsupported OVF allocation units %q", units)
+}
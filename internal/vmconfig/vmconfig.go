@@ -0,0 +1,27 @@
+// Package vmconfig reads the hardware of a source VM from its hypervisor
+// configuration file, for migrations.
+package vmconfig
+
+// VM is the hardware of a source VM.
+type VM struct {
+	Name     string
+	VCPUs    int
+	MemoryMB int
+	Disks    []Disk // boot disk first
+	NICs     []NIC
+}
+
+// Disk is a virtual disk attached to the VM.
+type Disk struct {
+	Device string // controller slot, e.g. scsi0:0
+	Path   string // absolute path of the disk file (VMDK descriptor for VMware), or its name inside an OVA
+	Bus    string // Nova disk_bus: scsi, sata, ide
+}
+
+// NIC is a virtual network adapter.
+type NIC struct {
+	Device  string // e.g. ethernet0
+	MAC     string
+	Network string // source network, e.g. a vSphere portgroup name
+	Model   string // e.g. vmxnet3, e1000
+}
@@ -0,0 +1,129 @@
+package vmconfig
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/facette/natsort"
+)
+
+var (
+	vmxDiskKey = regexp.MustCompile(`^(scsi|sata|ide)(\d+):(\d+)\.filename$`)
+	vmxNICKey  = regexp.MustCompile(`^(ethernet\d+)\.present$`)
+)
+
+// ParseVMX reads a VMware .vmx file. Disk file names are resolved
+// relative to the .vmx; CD-ROMs and devices that aren't present are skipped.
+func ParseVMX(path string) (VM, error) {
+	var vm VM
+
+	f, err := os.Open(path)
+	if err != nil {
+		return vm, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	// keys are case-insensitive, VMware writes both fileName and filename
+	kv := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		kv[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	if err := scanner.Err(); err != nil {
+		return vm, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	vm.Name = kv["displayname"]
+	vm.VCPUs = 1
+	if v, err := strconv.Atoi(kv["numvcpus"]); err == nil {
+		vm.VCPUs = v
+	}
+	if v, err := strconv.Atoi(kv["memsize"]); err == nil {
+		vm.MemoryMB = v
+	}
+
+	dir := filepath.Dir(path)
+	for key, value := range kv {
+		m := vmxDiskKey.FindStringSubmatch(key)
+		if m == nil {
+			continue
+		}
+		device := fmt.Sprintf("%s%s:%s", m[1], m[2], m[3])
+		if !vmxPresent(kv, device) || !vmxPresent(kv, m[1]+m[2]) {
+			continue
+		}
+		if strings.Contains(kv[device+".devicetype"], "cdrom") || !strings.HasSuffix(strings.ToLower(value), ".vmdk") {
+			continue
+		}
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(dir, value)
+		}
+		vm.Disks = append(vm.Disks, Disk{Device: device, Path: value, Bus: m[1]})
+	}
+
+	// boot from the disk named in bios.hddOrder, otherwise the first
+	// slot, with SCSI ahead of SATA and IDE
+	boot := strings.ToLower(strings.Split(kv["bios.hddorder"], ",")[0])
+	busOrder := map[string]int{"scsi": 0, "sata": 1, "ide": 2}
+	sort.Slice(vm.Disks, func(i, j int) bool {
+		a, b := vm.Disks[i], vm.Disks[j]
+		if (a.Device == boot) != (b.Device == boot) {
+			return a.Device == boot
+		}
+		if a.Bus != b.Bus {
+			return busOrder[a.Bus] < busOrder[b.Bus]
+		}
+		return natsort.Compare(a.Device, b.Device)
+	})
+
+	for key := range kv {
+		m := vmxNICKey.FindStringSubmatch(key)
+		if m == nil || !vmxPresent(kv, m[1]) {
+			continue
+		}
+		nic := NIC{Device: m[1], Model: kv[m[1]+".virtualdev"]}
+
+		// static addresses are in .address, generated and vCenter (vpx) ones in .generatedaddress
+		if strings.EqualFold(kv[m[1]+".addresstype"], "static") {
+			nic.MAC = kv[m[1]+".address"]
+		} else {
+			nic.MAC = kv[m[1]+".generatedaddress"]
+		}
+		nic.MAC = strings.ToLower(nic.MAC)
+
+		nic.Network = kv[m[1]+".networkname"]
+		if nic.Network == "" {
+			nic.Network = kv[m[1]+".dvs.portgroupid"]
+		}
+		vm.NICs = append(vm.NICs, nic)
+	}
+	sort.Slice(vm.NICs, func(i, j int) bool {
+		return natsort.Compare(vm.NICs[i].Device, vm.NICs[j].Device)
+	})
+
+	if len(vm.Disks) == 0 {
+		return vm, fmt.Errorf("%s has no disks", path)
+	}
+	return vm, nil
+}
+
+// vmxPresent reports whether a device (or controller) is marked present.
+// Disks without a .present key are treated as present.
+func vmxPresent(kv map[string]string, device string) bool {
+	present, ok := kv[device+".present"]
+	return !ok || strings.EqualFold(present, "true")
+}
@@ -0,0 +1,181 @@
+// Package vsphere is a minimal client for the vSphere Automation REST API
+// (vCenter 7.0U2 and later, and govmomi's vcsim), covering what a
+// migration needs: finding a VM and powering it off.
+package vsphere
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Power states reported by vCenter
+const (
+	PoweredOn  = "POWERED_ON"
+	PoweredOff = "POWERED_OFF"
+	Suspended  = "SUSPENDED"
+)
+
+// VM is a VM summary from the vCenter inventory
+type VM struct {
+	ID         string `json:"vm"`
+	Name       string `json:"name"`
+	PowerState string `json:"power_state"`
+	CPUCount   int    `json:"cpu_count"`
+	MemoryMiB  int    `json:"memory_size_MiB"`
+}
+
+// Client is a logged-in vCenter session
+type Client struct {
+	baseURL string
+	session string
+	http    *http.Client
+}
+
+// Login opens a session on host, which is a host name or an https URL.
+// With insecure set, the server certificate isn't verified, for vCenters
+// with self-signed certificates.
+func Login(host, username, password string, insecure bool) (*Client, error) {
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	c := &Client{
+		baseURL: strings.TrimSuffix(host, "/") + "/api",
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+			},
+		},
+	}
+
+	req, err := http.NewRequest("POST", c.baseURL+"/session", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.SetBasicAuth(username, password)
+
+	body, err := c.do(req, http.StatusCreated, http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("vCenter login failed: %v", err)
+	}
+	if err := json.Unmarshal(body, &c.session); err != nil {
+		return nil, fmt.Errorf("failed to parse vCenter session: %v", err)
+	}
+	return c, nil
+}
+
+// Logout ends the session
+func (c *Client) Logout() error {
+	_, err := c.call("DELETE", "/session", http.StatusNoContent, http.StatusOK)
+	return err
+}
+
+// FindVM returns the VM named name; names must be unique in vCenter for
+// this to succeed
+func (c *Client) FindVM(name string) (VM, error) {
+	var vms []VM
+	body, err := c.call("GET", "/vcenter/vm?names="+url.QueryEscape(name), http.StatusOK)
+	if err != nil {
+		return VM{}, fmt.Errorf("failed to list VMs: %v", err)
+	}
+	if err := json.Unmarshal(body, &vms); err != nil {
+		return VM{}, fmt.Errorf("failed to parse VM list: %v", err)
+	}
+
+	switch len(vms) {
+	case 0:
+		return VM{}, fmt.Errorf("no vSphere VM named %s", name)
+	case 1:
+		return vms[0], nil
+	}
+	return VM{}, fmt.Errorf("%d vSphere VMs are named %s", len(vms), name)
+}
+
+// PowerState returns the power state of a VM
+func (c *Client) PowerState(vmID string) (string, error) {
+	var power struct {
+		State string `json:"state"`
+	}
+	body, err := c.call("GET", "/vcenter/vm/"+vmID+"/power", http.StatusOK)
+	if err != nil {
+		return "", fmt.Errorf("failed to get power state: %v", err)
+	}
+	if err := json.Unmarshal(body, &power); err != nil {
+		return "", fmt.Errorf("failed to parse power state: %v", err)
+	}
+	return power.State, nil
+}
+
+// ShutdownGuest asks the guest OS to shut down through VMware Tools
+func (c *Client) ShutdownGuest(vmID string) error {
+	_, err := c.call("POST", "/vcenter/vm/"+vmID+"/guest/power?action=shutdown", http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("guest shutdown failed: %v", err)
+	}
+	return nil
+}
+
+// PowerOff powers a VM off without involving the guest
+func (c *Client) PowerOff(vmID string) error {
+	_, err := c.call("POST", "/vcenter/vm/"+vmID+"/power?action=stop", http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("power off failed: %v", err)
+	}
+	return nil
+}
+
+// WaitForPowerState polls a VM until it reaches state or timeout passes
+func (c *Client) WaitForPowerState(vmID, state string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := c.PowerState(vmID)
+		if err != nil {
+			return err
+		}
+		if current == state {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("VM still %s after %s", current, timeout)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// call sends a request in the session and checks the status code
+func (c *Client) call(method, path string, okStatus ...int) ([]byte, error) {
+	req, err := http.NewRequest(method, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("vmware-api-session-id", c.session)
+	return c.do(req, okStatus...)
+}
+
+// do sends req and returns the response body if the status is one of okStatus
+func (c *Client) do(req *http.Request, okStatus ...int) ([]byte, error) {
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	for _, status := range okStatus {
+		if resp.StatusCode == status {
+			return body, nil
+		}
+	}
+	return nil, fmt.Errorf("request failed [%d]: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}